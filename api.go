@@ -1,22 +1,104 @@
 package art
 
-type Tree interface {
-	Insert(key Key) bool
+import "io"
+
+// Reader is the read interface shared by all trees which can be iterated,
+// including the read only ImmutableTree and FrozenTree.
+type Reader[V any] interface {
+	// Search returns the value stored under key.
+	Search(key Key) (V, bool)
+	// Contains reports whether key is stored in the tree.
+	Contains(key Key) bool
+	// ForEachKeyPrefix returns keys under prefix in order.
 	ForEachKeyPrefix(prefix Key) []string
-	Iterator() Iterator
+	// Iterator returns an iterator over the tree in ascending order,
+	// it yields leaves only unless TraverseNode or TraverseAll is given.
+	Iterator(options ...int) Iterator[V]
 	Size() int
 }
 
-type Iterator interface {
+// Tree is an adaptive radix tree which maps keys to values of type V.
+type Tree[V any] interface {
+	Reader[V]
+
+	// Insert stores value under key, it returns the previous value and true if key already existed.
+	Insert(key Key, value V) (V, bool)
+	// Delete removes key from the tree, it returns the removed value and true if key existed.
+	Delete(key Key) (V, bool)
+	// InsertBatch inserts keys[i] with values[i], it returns what Insert returns for each key in the order of keys.
+	// It panics with ErrBatchLength if keys and values have different lengths.
+	InsertBatch(keys []Key, values []V) ([]V, []bool)
+	// SearchBatch returns what Search returns for each key in the order of keys.
+	SearchBatch(keys []Key) ([]V, []bool)
+	// Floor returns the greatest key which is less than or equal to key.
+	Floor(key Key) (Key, V, bool)
+	// Ceiling returns the least key which is greater than or equal to key.
+	Ceiling(key Key) (Key, V, bool)
+	// Predecessor returns the greatest key which is less than key.
+	Predecessor(key Key) (Key, V, bool)
+	// Successor returns the least key which is greater than key.
+	Successor(key Key) (Key, V, bool)
+	// LongestPrefix returns the longest key which is a prefix of key.
+	LongestPrefix(key Key) (Key, V, bool)
+	// Rank returns the number of keys which are less than key.
+	Rank(key Key) int
+	// Select returns the i'th key in order, i starts from 0.
+	Select(i int) (Key, V, bool)
+	// CountRange returns the number of keys in [start, end), a nil end means no upper bound.
+	CountRange(start, end Key) int
+	// Range calls callback with every leaf in [start, end) in order until callback returns false,
+	// a nil end means no upper bound, RangeExcludeStart and RangeIncludeEnd change the bounds.
+	Range(start, end Key, callback Callback[V], options ...int)
+	// Descend calls callback with every leaf in descending order until callback returns false.
+	Descend(callback Callback[V])
+	// DescendPrefix calls callback with every leaf under prefix in descending order until callback returns false.
+	DescendPrefix(prefix Key, callback Callback[V])
+	// ReverseIterator returns an iterator over leaves in descending order.
+	ReverseIterator() Iterator[V]
+	// Stats returns statistics of the nodes of the tree.
+	Stats() Stats
+	// Validate checks the invariants of the nodes of the tree, it returns an InvariantError for the first broken one.
+	Validate() error
+	// Dump writes the nodes of the tree to w in format, for debugging.
+	Dump(w io.Writer, format DumpFormat) error
+	// Cursor returns an unpositioned cursor, call one of its Seek methods before use.
+	Cursor() *Cursor[V]
+	// Watch returns a channel receiving events for keys under prefix, call cancel to stop watching,
+	// it closes the channel. Events are never blocked on, see OpOverflow for a slow receiver.
+	Watch(prefix Key) (<-chan Event[V], func())
+	// WriteTo writes a snapshot of the tree to w, it returns the number of bytes written.
+	WriteTo(w io.Writer) (int64, error)
+	// ReadFrom replaces the content of the tree by a snapshot read from r, the tree is unchanged on error.
+	// Nothing past the snapshot is read from r, which is read a byte at a time unless it is an io.ByteReader.
+	// Watchers are not notified.
+	ReadFrom(r io.Reader) (int64, error)
+
+	// InsertString is Insert with a string key.
+	InsertString(key string, value V) (V, bool)
+	// DeleteString is Delete with a string key.
+	DeleteString(key string) (V, bool)
+	// SearchString is Search with a string key.
+	SearchString(key string) (V, bool)
+	// ContainsString is Contains with a string key.
+	ContainsString(key string) bool
+}
+
+type Iterator[V any] interface {
 	HasNext() bool
-	Next() (Node, error)
+	Next() (Node[V], error)
 }
 
-type Node interface {
+type Node[V any] interface {
 	Type() NodeType
 	Key() Key
+	Value() V
+}
+
+func New[V any]() Tree[V] {
+	return &tree[V]{}
 }
 
-func New() Tree {
-	return &tree{}
+// NewWithCodec returns a tree which encodes values in snapshots by codec.
+func NewWithCodec[V any](codec Codec[V]) Tree[V] {
+	return &tree[V]{codec: codec}
 }
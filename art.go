@@ -2,6 +2,8 @@ package art
 
 import (
 	"errors"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -18,6 +20,56 @@ const (
 	traverseContinue
 )
 
+const (
+	// TraverseLeaf makes an iterator yield leaves only, it is the default
+	TraverseLeaf = 1 << iota
+	// TraverseNode makes an iterator yield inner nodes only
+	TraverseNode
+	// TraverseAll makes an iterator yield the whole structure of a tree, it is useful for debugging
+	TraverseAll = TraverseLeaf | TraverseNode
+)
+
+const (
+	// RangeExcludeStart makes the start of a range exclusive
+	RangeExcludeStart = 1 << iota
+	// RangeIncludeEnd makes the end of a range inclusive
+	RangeIncludeEnd
+)
+
+const (
+	// OpInsert is a key inserted into a tree
+	OpInsert Op = iota
+	// OpUpdate is a new value stored under an existing key
+	OpUpdate
+	// OpDelete is a key deleted from a tree
+	OpDelete
+	// OpOverflow means the watcher has not kept up and events have been dropped since,
+	// the watcher should read the tree again, delivery resumes once the channel is drained.
+	OpOverflow
+)
+
+const (
+	// DumpASCII renders a tree as indented lines
+	DumpASCII DumpFormat = iota
+	// DumpDOT renders a tree as a Graphviz digraph
+	DumpDOT
+)
+
+const (
+	// DiffAdded is a key only in the new tree
+	DiffAdded DiffType = iota
+	// DiffRemoved is a key only in the old tree
+	DiffRemoved
+	// DiffChanged is a key in both trees with different values
+	DiffChanged
+)
+
+const (
+	setUnion setOp = iota
+	setIntersect
+	setDifference
+)
+
 const (
 	// node constraints
 	node4Min = 2
@@ -40,52 +92,98 @@ const (
 	n48m = 64 // it should be sizeof(node48.present[0])
 
 	nullIdx = -1
+	// all children of a node has been visited by reverse iterator
+	doneIdx = -2
+
+	// first bytes of a snapshot, followed by the format version
+	snapshotMagic   = "ARTS"
+	snapshotVersion = 1
+
+	// first bytes of a frozen tree, they also end it after the size and the root offset
+	frozenMagic   = "ARTF"
+	frozenVersion = 1
+
+	// capacity of a watch channel, one more slot is kept for OpOverflow
+	watchBufferSize = 64
+
+	// bits of node.version
+	obsoleteBit = 1
+	lockedBit   = 2
 )
 
 var (
 	ErrNoMoreNodes = errors.New("There are no more nodes in the tree")
+	ErrTxnClosed   = errors.New("The transaction has been committed or aborted")
+	ErrTxnConflict = errors.New("The tree has been modified by another transaction")
+	ErrBatchLength = errors.New("The keys and values of the batch have different lengths")
+
+	// errors of snapshots, they are wrapped in a SnapshotError
+	ErrSnapshotFormat    = errors.New("The input is not a snapshot")
+	ErrSnapshotVersion   = errors.New("The snapshot version is not supported")
+	ErrSnapshotTruncated = errors.New("The snapshot is truncated")
+	ErrSnapshotCorrupt   = errors.New("The snapshot is corrupt")
+	ErrSnapshotChecksum  = errors.New("The snapshot checksum does not match")
+	ErrFrozenFormat      = errors.New("The data is not a frozen tree")
+	ErrUnsortedKeys      = errors.New("The keys are not in ascending order")
+	ErrDuplicateKey      = errors.New("The key is duplicated")
+	ErrDumpFormat        = errors.New("The dump format is unknown")
 )
 
 type (
-	tree struct {
+	tree[V any] struct {
 		size int
-		root *artNode
+		root *artNode[V]
+		// a copy-on-write tree modifies in place the inner nodes tagged with its owner,
+		// the others are shared with other trees. It is zero for a tree that owns all of its nodes.
+		owner uint64
+		// watchers of prefixes notified on every modification
+		watchers watchers[V]
+		// codec of values in snapshots, nil means GobCodec
+		codec Codec[V]
 	}
 
 	NodeType int
 	Key      []byte
 
-	artNode struct {
+	artNode[V any] struct {
 		_type NodeType
 		ref   unsafe.Pointer
 	}
 
 	// leaf node with variable key len
-	leaf struct {
-		key Key
+	leaf[V any] struct {
+		key   Key
+		value V
 	}
 	prefix [MaxPrefixLen]byte
 	// node header
-	node struct {
+	node[V any] struct {
 		prefixLen   uint32
 		prefix      prefix
 		numChildren uint16
+		// number of keys in the subtree
+		numKeys int
+		// lock word for optimistic lock coupling in ConcurrentTree,
+		// bit 0 marks an obsolete node, bit 1 a locked node, the rest is a version counter
+		version uint64
 		// a key with the null suffix will be stored as zeroChild
-		zeroChild *artNode
+		zeroChild *artNode[V]
+		// owner of the copy-on-write tree which created the node, see tree.owner
+		owner uint64
 	}
 	// node with 4 children
-	node4 struct {
-		node
+	node4[V any] struct {
+		node[V]
 
-		children [node4Max]*artNode
+		children [node4Max]*artNode[V]
 		keys     [node4Max]byte
 		// bool in go also uses 1 byte
 		present [node4Max]byte
 	}
-	node16 struct {
-		node
+	node16[V any] struct {
+		node[V]
 
-		children [node16Max]*artNode
+		children [node16Max]*artNode[V]
 		keys     [node16Max]byte
 		// bitmap present that if the key is present
 		present uint16
@@ -97,71 +195,321 @@ type (
 		If a node has between 17 and 48 child pointers, this array stores indexes into a second array which contains up to 48 pointers.
 		This indirection saves space in comparison to 256 pointers of 8 bytes, because the indexes only require 6 bits (we use 1 byte for simplicity).
 	*/
-	node48 struct {
-		node
+	node48[V any] struct {
+		node[V]
 
-		children [node48Max]*artNode
+		children [node48Max]*artNode[V]
 		keys     [node256Max]byte
 		// need 256 bits for keys
 		present [4]uint64
 	}
-	node256 struct {
-		node
+	node256[V any] struct {
+		node[V]
 
-		children [node256Max]*artNode
+		children [node256Max]*artNode[V]
 	}
 
-	Callback func(n Node) bool
+	// Callback is called for nodes visited by a traversal, returning false stops it
+	Callback[V any] func(n Node[V]) bool
 
 	traverseAction int
 
-	iteratorLevel struct {
-		node     *artNode
+	rangeBounds struct {
+		start        Key
+		end          Key
+		excludeStart bool
+		includeEnd   bool
+	}
+
+	iteratorLevel[V any] struct {
+		node     *artNode[V]
 		childIdx int
 	}
 
-	iterator struct {
-		tree       *tree
-		nextNode   *artNode
+	iterator[V any] struct {
+		tree       *tree[V]
+		options    int
+		nextNode   *artNode[V]
 		depthLevel int
-		depth      []*iteratorLevel
+		depth      []*iteratorLevel[V]
+	}
+
+	// Cursor is a position in the sorted keys of a tree, it moves in both directions.
+	// A cursor is invalidated by any modification of the tree.
+	Cursor[V any] struct {
+		tree  *tree[V]
+		leaf  *artNode[V]
+		depth []*iteratorLevel[V]
+	}
+
+	// ImmutableTree is a persistent tree, every modification returns a new tree
+	// which shares all unmodified nodes with the old one.
+	ImmutableTree[V any] struct {
+		tree *tree[V]
+	}
+
+	// Txn stages modifications of an ImmutableTree privately, inner nodes created by
+	// a transaction are modified in place until it is committed or aborted.
+	// Using a transaction after it is closed panics with ErrTxnClosed.
+	Txn[V any] struct {
+		base *ImmutableTree[V]
+		tree *tree[V]
+		// commit publishes the committed tree to, nil if the transaction is not bound to an AtomicTree
+		target *atomic.Value
+	}
+
+	// AtomicTree holds the current version of an ImmutableTree,
+	// readers load a version while transactions replace it atomically.
+	AtomicTree[V any] struct {
+		current atomic.Value
+	}
+
+	// ConcurrentTree is safe for concurrent use, writers lock only the nodes they modify
+	// and readers take no lock, they wait for a node being modified and restart when
+	// a node changed under them, see optimistic lock coupling in
+	// "The ART of Practical Synchronization" by Leis et al.
+	ConcurrentTree[V any] struct {
+		// root is accessed atomically
+		root *artNode[V]
+		// lock word of root
+		version uint64
+		size    int64
+	}
+
+	// Event describes a modification of a key observed by a watcher,
+	// Old is the zero value for OpInsert and New is the zero value for OpDelete.
+	// An OpOverflow event carries no key, it tells that events have been lost.
+	Event[V any] struct {
+		Op  Op
+		Key Key
+		Old V
+		New V
+	}
+
+	Op int
+
+	watchers[V any] struct {
+		sync.Mutex
+		// number of watchers, it is loaded atomically so that modifications skip the lock without watchers
+		count int32
+		list  map[*watcher[V]]struct{}
+	}
+
+	watcher[V any] struct {
+		prefix Key
+		events chan Event[V]
+		// events are dropped after an overflow until the channel is drained
+		overflowed bool
+	}
+
+	// Codec converts values to bytes and back for snapshots.
+	Codec[V any] interface {
+		Marshal(value V) ([]byte, error)
+		Unmarshal(data []byte) (V, error)
+	}
+
+	// GobCodec is the default codec, it encodes every value by encoding/gob.
+	GobCodec[V any] struct{}
+
+	// SnapshotError is returned for a snapshot which cannot be read,
+	// Err is one of the ErrSnapshot errors or an error of the codec.
+	SnapshotError struct {
+		// byte offset in the snapshot where reading failed
+		Offset int64
+		Err    error
+	}
+
+	// FrozenTree is a read only tree served from its encoded form, nodes refer to
+	// their children by offsets, so a file is used as it is mapped into memory.
+	FrozenTree[V any] struct {
+		data  []byte
+		root  uint64
+		size  int
+		codec Codec[V]
+		// unmap releases data of a tree opened from a file
+		unmap func() error
+	}
+
+	// frozenNode is a node of a FrozenTree at offset
+	frozenNode[V any] struct {
+		tree   *FrozenTree[V]
+		offset uint64
+	}
+
+	frozenIterator[V any] struct {
+		tree    *FrozenTree[V]
+		options int
+		// offsets of nodes to visit, the next one is on top
+		stack []uint64
+		next  *frozenNode[V]
+	}
+
+	// Stats describes the structure of a tree.
+	Stats struct {
+		// number of inner nodes per type
+		Nodes  map[NodeType]int
+		Leaves int
+		// depth of a leaf is the number of inner nodes above it
+		MaxDepth int
+		AvgDepth float64
+		// number of inner nodes per prefixLen
+		PrefixLens map[uint32]int
+		// inner nodes whose prefix is longer than MaxPrefixLen, they are matched through their minimum leaf
+		LongPrefixes int
+		// inner nodes having a zeroChild
+		ZeroChildren int
+		// average numChildren over the capacity of inner nodes per type
+		FillRatios map[NodeType]float64
+	}
+
+	// DumpFormat is a format of Dump
+	DumpFormat int
+
+	// InvariantError is returned by Validate for a broken tree,
+	// Path leads from the root to the broken node by the key bytes of children, zero is a zeroChild.
+	InvariantError struct {
+		Path   string
+		Reason string
+	}
+
+	// rooted is a tree whose nodes can be walked directly
+	rooted[V any] interface {
+		rootNode() *artNode[V]
+	}
+
+	setOp int
+
+	// Change is a difference of a key between two trees,
+	// Old is the zero value for DiffAdded and New is the zero value for DiffRemoved.
+	Change[V any] struct {
+		Type DiffType
+		Key  Key
+		Old  V
+		New  V
+	}
+
+	DiffType int
+
+	differ[V any] struct {
+		callback func(Change[V]) bool
+	}
+
+	// setView is a node whose first skip prefix bytes have been consumed,
+	// it lets the walk of two trees compare prefixes of different lengths.
+	setView[V any] struct {
+		n    *artNode[V]
+		skip uint32
+	}
+
+	// setChild is a child of a view under key byte c
+	setChild[V any] struct {
+		c byte
+		v setView[V]
+	}
+
+	// sortedBuilder builds a subtree from sorted and unique keys in a single pass,
+	// open holds the inner nodes from the root down whose last child may still get keys.
+	sortedBuilder[V any] struct {
+		// keys share their first depth bytes, the prefix of the root starts there
+		depth uint32
+		open  []openNode[V]
+		// last is the subtree of the previous key, it becomes the last child of an open node
+		last builtNode[V]
+		prev Key
+		size int
+	}
+
+	// openNode is an inner node being built, children are added under bytes at end
+	openNode[V any] struct {
+		end uint32
+		// least key of the node
+		first    Key
+		zero     *artNode[V]
+		keys     []byte
+		children []*artNode[V]
+		numKeys  int
+	}
+
+	// builtNode is a subtree whose prefix is set once it is added to its parent
+	builtNode[V any] struct {
+		n *artNode[V]
+		// least key of the subtree
+		first Key
+		// end of the prefix of an inner node
+		end     uint32
+		numKeys int
+	}
+
+	setMerger[V any] struct {
+		op    setOp
+		merge func(key Key, a, b V) V
+		// owner of the nodes created for the result when it shares nodes with immutable inputs
+		owner uint64
+	}
+
+	// ShardedTree partitions keys across independent trees by their leading bytes,
+	// every shard is guarded by its own lock. Shards hold consecutive key ranges,
+	// so the keys of a shard are all less than the keys of the next one.
+	ShardedTree[V any] struct {
+		shards []shard[V]
+		// number of leading key bytes a shard is chosen by
+		prefixLen int
+	}
+
+	shard[V any] struct {
+		sync.RWMutex
+		tree *tree[V]
+	}
+
+	// shardedIterator visits shards in order, leaves of a shard are copied under its read lock
+	shardedIterator[V any] struct {
+		tree   *ShardedTree[V]
+		next   int
+		leaves []Node[V]
+	}
+
+	// reverseIterator visits leaves in descending order
+	reverseIterator[V any] struct {
+		nextNode *artNode[V]
+		depth    []*iteratorLevel[V]
 	}
 )
 
-func newNode4() *artNode {
-	return &artNode{
+func newNode4[V any]() *artNode[V] {
+	return &artNode[V]{
 		_type: Node4,
-		ref:   unsafe.Pointer(&node4{}),
+		ref:   unsafe.Pointer(&node4[V]{}),
 	}
 }
 
-func newNode16() *artNode {
-	return &artNode{
+func newNode16[V any]() *artNode[V] {
+	return &artNode[V]{
 		_type: Node16,
-		ref:   unsafe.Pointer(&node16{}),
+		ref:   unsafe.Pointer(&node16[V]{}),
 	}
 }
 
-func newNode48() *artNode {
-	return &artNode{
+func newNode48[V any]() *artNode[V] {
+	return &artNode[V]{
 		_type: Node48,
-		ref:   unsafe.Pointer(&node48{}),
+		ref:   unsafe.Pointer(&node48[V]{}),
 	}
 }
 
-func newNode256() *artNode {
-	return &artNode{
+func newNode256[V any]() *artNode[V] {
+	return &artNode[V]{
 		_type: Node256,
-		ref:   unsafe.Pointer(&node256{}),
+		ref:   unsafe.Pointer(&node256[V]{}),
 	}
 }
 
-func newLeaf(key Key) *artNode {
+func newLeaf[V any](key Key, value V) *artNode[V] {
 	clonedKey := append([]byte(nil), key...)
-	return &artNode{
+	return &artNode[V]{
 		_type: Leaf,
-		ref: unsafe.Pointer(&leaf{
-			key: clonedKey,
+		ref: unsafe.Pointer(&leaf[V]{
+			key:   clonedKey,
+			value: value,
 		}),
 	}
 }
@@ -0,0 +1,161 @@
+package art
+
+import (
+	"bytes"
+	"sort"
+)
+
+// InsertBatch inserts keys[i] with values[i], keys and values must have the same length.
+// It returns the previous values and whether each key existed, in the order of keys.
+// Keys are sorted first, so nodes shared by keys of the batch are visited once,
+// a key repeated in the batch behaves as if the batch were inserted one by one.
+// It panics with ErrBatchLength before modifying the tree if the lengths differ.
+func (t *tree[V]) InsertBatch(keys []Key, values []V) ([]V, []bool) {
+	if len(keys) != len(values) {
+		panic(ErrBatchLength)
+	}
+	old := make([]V, len(keys))
+	updated := make([]bool, len(keys))
+	b := &batch[V]{keys: keys, values: values, old: old, found: updated}
+
+	t.size += t.insertBatch(&t.root, b.sorted(), b, 0)
+
+	for i, key := range keys {
+		if updated[i] {
+			t.watchers.notify(OpUpdate, key, old[i], values[i])
+		} else {
+			t.watchers.notify(OpInsert, key, old[i], values[i])
+		}
+	}
+	return old, updated
+}
+
+// SearchBatch searches keys, it returns the values and whether each key exists in the order of keys.
+func (t *tree[V]) SearchBatch(keys []Key) ([]V, []bool) {
+	values := make([]V, len(keys))
+	found := make([]bool, len(keys))
+	b := &batch[V]{keys: keys, old: values, found: found}
+
+	t.searchBatch(t.root, b.sorted(), b, 0)
+	return values, found
+}
+
+// batch holds the keys of a batch and its results, both in the caller's order
+type batch[V any] struct {
+	keys   []Key
+	values []V
+	old    []V
+	found  []bool
+}
+
+// sorted returns indexes of keys in key order, equal keys keep their order in the batch
+func (b *batch[V]) sorted() []int {
+	idx := make([]int, len(b.keys))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return bytes.Compare(b.keys[idx[i]], b.keys[idx[j]]) < 0
+	})
+	return idx
+}
+
+// groupEnd returns the end of the group of idx[start:] sharing the byte at depth,
+// keys ending before depth form a group as well
+func (b *batch[V]) groupEnd(idx []int, start int, depth uint32) int {
+	first := b.keys[idx[start]]
+	end := start + 1
+	for end < len(idx) {
+		key := b.keys[idx[end]]
+		if key.valid(int(depth)) != first.valid(int(depth)) || key.charAt(int(depth)) != first.charAt(int(depth)) {
+			break
+		}
+		end++
+	}
+	return end
+}
+
+// insertBatch inserts the sorted keys idx under curNode, it returns the number of inserted keys
+func (t *tree[V]) insertBatch(curNode **artNode[V], idx []int, b *batch[V], depth uint32) int {
+	curr := *curNode
+	if len(idx) == 1 || curr == nil || curr.isLeaf() {
+		return t.insertEach(curNode, idx, b, depth)
+	}
+
+	prefixLen := curr.node().prefixLen
+	for _, i := range idx {
+		if curr.matchDeep(b.keys[i], depth) < prefixLen {
+			// the prefix is split by some key
+			return t.insertEach(curNode, idx, b, depth)
+		}
+	}
+
+	curr = t.writable(curNode)
+	childDepth := depth + prefixLen
+	inserted := 0
+	for start := 0; start < len(idx); {
+		end := b.groupEnd(idx, start, childDepth)
+		key := b.keys[idx[start]]
+
+		// curr may have grown by the previous group, so the child is looked up again
+		next := curr.findChild(key.charAt(int(childDepth)), key.valid(int(childDepth)))
+		if next != nil && *next != nil {
+			n := t.insertBatch(next, idx[start:end], b, childDepth+1)
+			curr.node().numKeys += n
+			inserted += n
+		} else {
+			// the first key adds the child, the others go through it
+			inserted += t.insertEach(curNode, idx[start:end], b, depth)
+		}
+		start = end
+	}
+	return inserted
+}
+
+// insertEach inserts the sorted keys idx one by one starting at curNode
+func (t *tree[V]) insertEach(curNode **artNode[V], idx []int, b *batch[V], depth uint32) int {
+	inserted := 0
+	for _, i := range idx {
+		b.old[i], b.found[i] = t.recursiveInsert(curNode, b.keys[i], b.values[i], depth)
+		if !b.found[i] {
+			inserted++
+		}
+	}
+	return inserted
+}
+
+func (t *tree[V]) searchBatch(curr *artNode[V], idx []int, b *batch[V], depth uint32) {
+	if curr == nil {
+		return
+	}
+
+	if curr.isLeaf() {
+		leaf := curr.leaf()
+		for _, i := range idx {
+			if leaf.match(b.keys[i]) {
+				b.old[i], b.found[i] = leaf.value, true
+			}
+		}
+		return
+	}
+
+	node := curr.node()
+	if node.prefixLen > 0 {
+		limit := min(node.prefixLen, MaxPrefixLen)
+		matched := idx[:0:0]
+		for _, i := range idx {
+			if curr.match(b.keys[i], depth) == limit {
+				matched = append(matched, i)
+			}
+		}
+		idx = matched
+		depth += node.prefixLen
+	}
+
+	for start := 0; start < len(idx); {
+		end := b.groupEnd(idx, start, depth)
+		key := b.keys[idx[start]]
+		t.searchBatch(curr.child(key.charAt(int(depth)), key.valid(int(depth))), idx[start:end], b, depth+1)
+		start = end
+	}
+}
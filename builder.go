@@ -0,0 +1,139 @@
+package art
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// BuildFromSorted builds a tree from the leaves yielded by it, which must be in ascending order.
+// Inner nodes are created once with their final type and prefix instead of growing by Insert,
+// the tree is built as leaves are yielded. It returns ErrUnsortedKeys or ErrDuplicateKey if the order is broken.
+func BuildFromSorted[V any](it Iterator[V]) (Tree[V], error) {
+	b := &sortedBuilder[V]{}
+	for it.HasNext() {
+		n, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if n.Type() != Leaf {
+			continue
+		}
+
+		key := append(Key(nil), n.Key()...)
+		if b.size > 0 {
+			switch bytes.Compare(b.prev, key) {
+			case 0:
+				return nil, ErrDuplicateKey
+			case 1:
+				return nil, ErrUnsortedKeys
+			}
+		}
+		b.add(key, n.Value())
+	}
+
+	return &tree[V]{root: b.finish(), size: b.size}, nil
+}
+
+// buildSorted builds a subtree from keys which are sorted and unique,
+// every inner node is created with its final type and key count, so nothing grows on the way.
+// keys must share their first depth bytes, they are not copied into leaves.
+func buildSorted[V any](keys []Key, values []V, depth uint32) *artNode[V] {
+	b := &sortedBuilder[V]{depth: depth}
+	for i, key := range keys {
+		b.add(key, values[i])
+	}
+	return b.finish()
+}
+
+// add adds a key greater than the previous one, the open nodes branching after
+// the prefix it shares with the previous key are closed.
+func (b *sortedBuilder[V]) add(key Key, value V) {
+	leaf := builtNode[V]{
+		n:       &artNode[V]{_type: Leaf, ref: unsafe.Pointer(&leaf[V]{key: key, value: value})},
+		first:   key,
+		numKeys: 1,
+	}
+	if b.size == 0 {
+		b.last, b.prev, b.size = leaf, key, 1
+		return
+	}
+
+	lcp := b.depth
+	for int(lcp) < len(b.prev) && int(lcp) < len(key) && b.prev[lcp] == key[lcp] {
+		lcp++
+	}
+	for len(b.open) > 0 && b.open[len(b.open)-1].end > lcp {
+		b.close()
+	}
+	if len(b.open) == 0 || b.open[len(b.open)-1].end < lcp {
+		// the previous subtree and key branch at lcp
+		b.open = append(b.open, openNode[V]{end: lcp})
+	}
+	b.open[len(b.open)-1].add(b.last)
+	b.last, b.prev = leaf, key
+	b.size++
+}
+
+// finish closes the open nodes and returns the root
+func (b *sortedBuilder[V]) finish() *artNode[V] {
+	if b.size == 0 {
+		return nil
+	}
+	for len(b.open) > 0 {
+		b.close()
+	}
+	if !b.last.n.isLeaf() {
+		b.last.n.setPrefix(b.last.first[b.depth:], b.last.end-b.depth)
+	}
+	return b.last.n
+}
+
+// close adds the last subtree to the deepest open node, which becomes the last subtree
+func (b *sortedBuilder[V]) close() {
+	o := &b.open[len(b.open)-1]
+	o.add(b.last)
+	b.last = o.build()
+	b.open = b.open[:len(b.open)-1]
+}
+
+// add adds a subtree after the children of the node
+func (o *openNode[V]) add(child builtNode[V]) {
+	if o.numKeys == 0 {
+		o.first = child.first
+	}
+	o.numKeys += child.numKeys
+	if !child.n.isLeaf() {
+		child.n.setPrefix(child.first[o.end+1:], child.end-o.end-1)
+	}
+
+	if !child.first.valid(int(o.end)) {
+		o.zero = child.n
+		return
+	}
+	o.keys = append(o.keys, child.first[o.end])
+	o.children = append(o.children, child.n)
+}
+
+// build creates the node with its final type
+func (o *openNode[V]) build() builtNode[V] {
+	var an *artNode[V]
+	switch n := len(o.children); {
+	case n <= node4Max:
+		an = newNode4[V]()
+	case n <= node16Max:
+		an = newNode16[V]()
+	case n <= node48Max:
+		an = newNode48[V]()
+	default:
+		an = newNode256[V]()
+	}
+	an.node().numKeys = o.numKeys
+
+	if o.zero != nil {
+		an.addChild(0, false, o.zero)
+	}
+	for i, child := range o.children {
+		an.addChild(o.keys[i], true, child)
+	}
+	return builtNode[V]{n: an, first: o.first, end: o.end, numKeys: o.numKeys}
+}
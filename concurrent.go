@@ -0,0 +1,790 @@
+package art
+
+import (
+	"bytes"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Writers of ConcurrentTree lock the nodes they modify, readers take no lock: they read the
+// lock word of a node before and after reading the node and restart if it changed meanwhile.
+// A child is added to a free slot and removed in place, a node which grows, shrinks, collapses
+// or has its prefix split is replaced by a modified copy and marked obsolete, so are leaves.
+// What readers load from a locked node, child pointers, keys of node4, node16 and node48
+// and the present bits of node48, is loaded and stored atomically, prefixes and types of
+// published nodes never change. Writers restart once they meet an obsolete node.
+
+func NewConcurrent[V any]() *ConcurrentTree[V] {
+	return &ConcurrentTree[V]{}
+}
+
+func (t *ConcurrentTree[V]) Size() int {
+	return int(atomic.LoadInt64(&t.size))
+}
+
+func (t *ConcurrentTree[V]) Insert(key Key, value V) (V, bool) {
+	var zero V
+
+RESTART:
+	for {
+		parent := &t.version
+		parentVersion, ok := readLock(parent)
+		if !ok {
+			continue
+		}
+		slot := &t.root
+		depth := uint32(0)
+
+		for {
+			curr := loadChild(slot)
+			if !checkVersion(parent, parentVersion) {
+				continue RESTART
+			}
+
+			if curr == nil {
+				if !upgradeLock(parent, parentVersion) {
+					continue RESTART
+				}
+				storeChild(slot, newLeaf(key, value))
+				unlock(parent)
+
+				atomic.AddInt64(&t.size, 1)
+				return zero, false
+			}
+
+			if curr.isLeaf() {
+				leaf := curr.leaf()
+				updated := leaf.match(key)
+
+				// leaves are immutable, an updated leaf is replaced as well
+				replacement := newLeaf(key, value)
+				if !updated {
+					replacement = splitLeaf(curr, replacement, depth)
+				}
+
+				if !upgradeLock(parent, parentVersion) {
+					continue RESTART
+				}
+				storeChild(slot, replacement)
+				unlock(parent)
+
+				if updated {
+					return leaf.value, true
+				}
+				atomic.AddInt64(&t.size, 1)
+				return zero, false
+			}
+
+			node := curr.node()
+			version := &node.version
+			v, ok := readLock(version)
+			if !ok {
+				continue RESTART
+			}
+
+			if node.prefixLen > 0 {
+				mismatchIdx := prefixMismatch(curr, key, depth)
+				if mismatchIdx < node.prefixLen {
+					// new node4 replaces curr, a copy of curr with the rest of prefix goes under it
+					if !upgradeLock(parent, parentVersion) {
+						continue RESTART
+					}
+					if !upgradeLock(version, v) {
+						unlock(parent)
+						continue RESTART
+					}
+					storeChild(slot, splitPrefix(curr, newLeaf(key, value), depth, mismatchIdx))
+					unlockObsolete(version)
+					unlock(parent)
+
+					atomic.AddInt64(&t.size, 1)
+					return zero, false
+				}
+				depth += node.prefixLen
+			}
+
+			c, valid := key.charAt(int(depth)), key.valid(int(depth))
+			next := findSharedChild(curr, c, valid)
+			if next == nil || loadChild(next) == nil {
+				if !upgradeLock(version, v) {
+					continue RESTART
+				}
+				if !isFull(curr, valid) {
+					addSharedChild(curr, c, valid, newLeaf(key, value))
+					unlock(version)
+
+					atomic.AddInt64(&t.size, 1)
+					return zero, false
+				}
+
+				// a grown copy of curr with the new child replaces curr
+				if !upgradeLock(parent, parentVersion) {
+					unlock(version)
+					continue RESTART
+				}
+				replacement := lockedClone(curr)
+				replacement.addChild(c, valid, newLeaf(key, value))
+				storeChild(slot, replacement)
+				unlockObsolete(version)
+				unlock(parent)
+
+				atomic.AddInt64(&t.size, 1)
+				return zero, false
+			}
+
+			parent, parentVersion, slot = version, v, next
+			depth++
+		}
+	}
+}
+
+func (t *ConcurrentTree[V]) Delete(key Key) (V, bool) {
+	var zero V
+
+RESTART:
+	for {
+		parent := &t.version
+		parentVersion, ok := readLock(parent)
+		if !ok {
+			continue
+		}
+		slot := &t.root
+		depth := uint32(0)
+
+		curr := loadChild(slot)
+		if !checkVersion(parent, parentVersion) {
+			continue
+		}
+		if curr == nil {
+			return zero, false
+		}
+
+		if curr.isLeaf() {
+			leaf := curr.leaf()
+			if !leaf.match(key) {
+				return zero, false
+			}
+			if !upgradeLock(parent, parentVersion) {
+				continue
+			}
+			storeChild(slot, nil)
+			unlock(parent)
+
+			atomic.AddInt64(&t.size, -1)
+			return leaf.value, true
+		}
+
+		for {
+			node := curr.node()
+			version := &node.version
+			v, ok := readLock(version)
+			if !ok {
+				continue RESTART
+			}
+
+			if node.prefixLen > 0 {
+				if curr.match(key, depth) != min(node.prefixLen, MaxPrefixLen) {
+					return zero, false
+				}
+				depth += node.prefixLen
+			}
+
+			c, valid := key.charAt(int(depth)), key.valid(int(depth))
+			next := findSharedChild(curr, c, valid)
+			if next == nil {
+				if !checkVersion(version, v) {
+					continue RESTART
+				}
+				return zero, false
+			}
+			child := loadChild(next)
+			if !checkVersion(version, v) {
+				continue RESTART
+			}
+			if child == nil {
+				return zero, false
+			}
+
+			if !child.isLeaf() {
+				parent, parentVersion, slot, curr = version, v, next, child
+				depth++
+				continue
+			}
+
+			leaf := child.leaf()
+			if !leaf.match(key) {
+				return zero, false
+			}
+
+			if !upgradeLock(version, v) {
+				continue RESTART
+			}
+			if !removeShrinks(curr, valid) {
+				removeSharedChild(curr, c, valid)
+				unlock(version)
+
+				atomic.AddInt64(&t.size, -1)
+				return leaf.value, true
+			}
+
+			// a copy of curr without the leaf replaces curr, it shrinks or collapses
+			if !upgradeLock(parent, parentVersion) {
+				unlock(version)
+				continue RESTART
+			}
+
+			// the last child left is merged with curr, it is replaced as well
+			last := lastChild(curr, c, valid)
+			var lastVersion *uint64
+			if last != nil && !last.isLeaf() {
+				lastVersion = &last.node().version
+				lv, ok := readLock(lastVersion)
+				if !ok || !upgradeLock(lastVersion, lv) {
+					unlock(version)
+					unlock(parent)
+					continue RESTART
+				}
+			}
+
+			replacement := lockedClone(curr)
+			replacement.removeChild(c, valid)
+			storeChild(slot, replacement)
+
+			if lastVersion != nil {
+				unlockObsolete(lastVersion)
+			}
+			unlockObsolete(version)
+			unlock(parent)
+
+			atomic.AddInt64(&t.size, -1)
+			return leaf.value, true
+		}
+	}
+}
+
+func (t *ConcurrentTree[V]) Search(key Key) (V, bool) {
+	var zero V
+
+RESTART:
+	for {
+		parent := &t.version
+		parentVersion, ok := readLock(parent)
+		if !ok {
+			continue
+		}
+		slot := &t.root
+		depth := uint32(0)
+
+		for {
+			curr := loadChild(slot)
+			if !checkVersion(parent, parentVersion) {
+				continue RESTART
+			}
+
+			if curr == nil {
+				return zero, false
+			}
+
+			if curr.isLeaf() {
+				leaf := curr.leaf()
+				if leaf.match(key) {
+					return leaf.value, true
+				}
+				return zero, false
+			}
+
+			node := curr.node()
+			version := &node.version
+			v, ok := readLock(version)
+			if !ok {
+				continue RESTART
+			}
+
+			// bytes beyond MaxPrefixLen are verified at leaf
+			if node.prefixLen > 0 {
+				if curr.match(key, depth) != min(node.prefixLen, MaxPrefixLen) {
+					return zero, false
+				}
+				depth += node.prefixLen
+			}
+
+			next := findSharedChild(curr, key.charAt(int(depth)), key.valid(int(depth)))
+			if next == nil {
+				if !checkVersion(version, v) {
+					continue RESTART
+				}
+				return zero, false
+			}
+
+			parent, parentVersion, slot = version, v, next
+			depth++
+		}
+	}
+}
+
+func (t *ConcurrentTree[V]) Contains(key Key) bool {
+	_, found := t.Search(key)
+	return found
+}
+
+// ForEachKeyPrefix returns keys under prefix in order,
+// keys inserted or deleted during the scan may or may not be seen.
+func (t *ConcurrentTree[V]) ForEachKeyPrefix(prefix Key) []string {
+	keys := make([]string, 0)
+	collect := func(l *leaf[V]) {
+		if bytes.HasPrefix(l.key, prefix) {
+			keys = append(keys, l.key.String())
+		}
+	}
+
+	curr := loadChild(&t.root)
+	depth := uint32(0)
+	for curr != nil {
+		if curr.isLeaf() {
+			collect(curr.leaf())
+			break
+		}
+
+		node := curr.node()
+		if depth >= uint32(len(prefix)) {
+			walkChildren(curr, collect)
+			break
+		}
+		if node.prefixLen > 0 {
+			limit := min(min(node.prefixLen, MaxPrefixLen), uint32(len(prefix))-depth)
+			if curr.match(prefix, depth) != limit {
+				break
+			}
+			if depth+node.prefixLen >= uint32(len(prefix)) {
+				walkChildren(curr, collect)
+				break
+			}
+			depth += node.prefixLen
+		}
+
+		curr = sharedChild(curr, prefix[depth], true)
+		depth++
+	}
+
+	return keys
+}
+
+// walkChildren calls f with leaves under n in order
+func walkChildren[V any](n *artNode[V], f func(*leaf[V])) {
+	if n == nil {
+		return
+	}
+	if n.isLeaf() {
+		f(n.leaf())
+		return
+	}
+	for _, child := range sharedChildren(n) {
+		walkChildren(child, f)
+	}
+}
+
+// sharedChild returns the child under c of a node shared by goroutines,
+// the node is read again until no writer modified it meanwhile
+func sharedChild[V any](n *artNode[V], c byte, valid bool) *artNode[V] {
+	version := &n.node().version
+	for {
+		v, _ := readLock(version)
+		var child *artNode[V]
+		if slot := findSharedChild(n, c, valid); slot != nil {
+			child = loadChild(slot)
+		}
+		if checkVersion(version, v) {
+			return child
+		}
+	}
+}
+
+// sharedChildren returns zeroChild and the children of a node shared by goroutines in order,
+// the node is read again until no writer modified it meanwhile
+func sharedChildren[V any](n *artNode[V]) []*artNode[V] {
+	node := n.node()
+	var children []*artNode[V]
+	add := func(slot **artNode[V]) {
+		if child := loadChild(slot); child != nil {
+			children = append(children, child)
+		}
+	}
+
+	for {
+		v, _ := readLock(&node.version)
+		children = children[:0]
+		add(&node.zeroChild)
+		switch n._type {
+		case Node4:
+			for i := 0; i < node4Max; i++ {
+				add(&n.node4().children[i])
+			}
+		case Node16:
+			for i := 0; i < node16Max; i++ {
+				add(&n.node16().children[i])
+			}
+		case Node48:
+			n48 := n.node48()
+			for c := 0; c < node256Max; c++ {
+				if atomic.LoadUint64(&n48.present[c>>n48s])&(1<<(c%n48m)) != 0 {
+					add(&n48.children[loadKey(n48.keys[:], c)])
+				}
+			}
+		case Node256:
+			for i := 0; i < node256Max; i++ {
+				add(&n.node256().children[i])
+			}
+		}
+		if checkVersion(&node.version, v) {
+			return children
+		}
+	}
+}
+
+// loadMinimum is minimum of a node shared by goroutines
+func loadMinimum[V any](n *artNode[V]) *leaf[V] {
+	for n != nil && !n.isLeaf() {
+		if zeroChild := loadChild(&n.node().zeroChild); zeroChild != nil {
+			n = zeroChild
+			continue
+		}
+
+		switch n._type {
+		case Node4:
+			n = loadChild(&n.node4().children[0])
+		case Node16:
+			n = loadChild(&n.node16().children[0])
+		case Node48:
+			node := n.node48()
+			idx := 0
+			for idx < node256Max && atomic.LoadUint64(&node.present[idx>>n48s])&(1<<(idx%n48m)) == 0 {
+				idx++
+			}
+			if idx == node256Max {
+				return nil
+			}
+			n = loadChild(&node.children[loadKey(node.keys[:], idx)])
+		case Node256:
+			node := n.node256()
+			var child *artNode[V]
+			for i := 0; child == nil && i < node256Max; i++ {
+				child = loadChild(&node.children[i])
+			}
+			n = child
+		}
+	}
+
+	if n == nil {
+		return nil
+	}
+	return n.leaf()
+}
+
+// prefixMismatch is matchDeep of a node shared by goroutines
+func prefixMismatch[V any](n *artNode[V], key Key, depth uint32) uint32 {
+	node := n.node()
+	idx := n.match(key, depth)
+	if idx < MaxPrefixLen || node.prefixLen <= MaxPrefixLen {
+		return idx
+	}
+
+	leaf := loadMinimum(n)
+	if leaf == nil {
+		// a writer is modifying n, it is checked when n is locked
+		return idx
+	}
+	for ; idx < node.prefixLen; idx++ {
+		pos := int(depth + idx)
+		if pos >= len(key) || leaf.key[pos] != key[pos] {
+			break
+		}
+	}
+	return idx
+}
+
+// splitLeaf returns a new node4 with both leaves
+func splitLeaf[V any](old, new *artNode[V], depth uint32) *artNode[V] {
+	l1, l2 := old.leaf(), new.leaf()
+	lcp := longestCommonPrefix(l1, l2, depth)
+
+	n := newNode4[V]()
+	n.setPrefix(l2.key[depth:], lcp)
+	depth += lcp
+
+	n.addChild(l1.key.charAt(int(depth)), l1.key.valid(int(depth)), old)
+	n.addChild(l2.key.charAt(int(depth)), l2.key.valid(int(depth)), new)
+	return n
+}
+
+// splitPrefix returns a new node4 with the leaf and a copy of locked curr,
+// the prefix of curr is split at mismatchIdx.
+func splitPrefix[V any](curr, newLeaf *artNode[V], depth, mismatchIdx uint32) *artNode[V] {
+	key := newLeaf.leaf().key
+
+	n := newNode4[V]()
+	n.setPrefix(key[depth:], mismatchIdx)
+
+	child := lockedClone(curr)
+	node := child.node()
+
+	// bytes of the prefix after the mismatch
+	rest := node.prefix[:]
+	if node.prefixLen > MaxPrefixLen {
+		rest = loadMinimum(curr).key[depth:]
+	}
+	c := rest[mismatchIdx]
+
+	node.prefixLen -= mismatchIdx + 1
+	start := mismatchIdx + 1
+	copy(node.prefix[:], rest[start:start+min(node.prefixLen, MaxPrefixLen)])
+
+	n.addChild(c, true, child)
+	n.addChild(key.charAt(int(depth+mismatchIdx)), key.valid(int(depth+mismatchIdx)), newLeaf)
+	return n
+}
+
+// lastChild returns the child left in a locked node4 after removing the child under c
+func lastChild[V any](n *artNode[V], c byte, valid bool) *artNode[V] {
+	if n._type != Node4 {
+		return nil
+	}
+
+	node := n.node4()
+	zeroChild := loadChild(&node.zeroChild)
+	numChildren := int(node.numChildren)
+	if zeroChild != nil {
+		numChildren++
+	}
+	if numChildren != node4Min {
+		return nil
+	}
+
+	if !valid {
+		return loadChild(&node.children[0])
+	}
+	if zeroChild != nil {
+		return zeroChild
+	}
+	if node.keys[0] == c {
+		return loadChild(&node.children[1])
+	}
+	return loadChild(&node.children[0])
+}
+
+// isFull reports whether a locked node has no free slot for a child under a valid byte
+func isFull[V any](n *artNode[V], valid bool) bool {
+	if !valid {
+		return false
+	}
+	numChildren := int(n.node().numChildren)
+	switch n._type {
+	case Node4:
+		return numChildren >= node4Max
+	case Node16:
+		return numChildren >= node16Max
+	case Node48:
+		return numChildren >= node48Max
+	}
+	return false
+}
+
+// removeShrinks reports whether removing a child from a locked node shrinks or collapses it
+func removeShrinks[V any](n *artNode[V], valid bool) bool {
+	node := n.node()
+	numChildren := int(node.numChildren)
+	switch n._type {
+	case Node4:
+		if node.zeroChild != nil {
+			numChildren++
+		}
+		return numChildren-1 < node4Min
+	case Node16:
+		return valid && numChildren-1 < node16Min
+	case Node48:
+		return valid && numChildren-1 < node48Min
+	case Node256:
+		return valid && numChildren-1 < node256Min
+	}
+	return false
+}
+
+// findSharedChild is findChild of a node shared by goroutines
+func findSharedChild[V any](n *artNode[V], c byte, valid bool) **artNode[V] {
+	if !valid {
+		return &n.node().zeroChild
+	}
+
+	switch n._type {
+	case Node4:
+		node := n.node4()
+		for i := 0; i < node4Max; i++ {
+			if loadKey(node.keys[:], i) == c && loadChild(&node.children[i]) != nil {
+				return &node.children[i]
+			}
+		}
+	case Node16:
+		node := n.node16()
+		for i := 0; i < node16Max; i++ {
+			if loadKey(node.keys[:], i) == c && loadChild(&node.children[i]) != nil {
+				return &node.children[i]
+			}
+		}
+	case Node48:
+		node := n.node48()
+		if atomic.LoadUint64(&node.present[c>>n48s])&(1<<(c%n48m)) != 0 {
+			return &node.children[loadKey(node.keys[:], int(c))]
+		}
+	case Node256:
+		return &n.node256().children[c]
+	}
+	return nil
+}
+
+// addSharedChild adds a child to a free slot of a node locked by the caller
+func addSharedChild[V any](n *artNode[V], c byte, valid bool, child *artNode[V]) {
+	node := n.node()
+	if !valid {
+		storeChild(&node.zeroChild, child)
+		return
+	}
+
+	numChildren := int(node.numChildren)
+	switch n._type {
+	case Node4:
+		n4 := n.node4()
+		insertShared(n4.keys[:], n4.children[:], numChildren, c, child)
+		n4.present[numChildren] = 1
+	case Node16:
+		n16 := n.node16()
+		insertShared(n16.keys[:], n16.children[:], numChildren, c, child)
+		n16.present |= 1 << numChildren
+	case Node48:
+		n48 := n.node48()
+		idx := 0
+		for n48.children[idx] != nil {
+			idx++
+		}
+		storeChild(&n48.children[idx], child)
+		storeKey(n48.keys[:], int(c), byte(idx))
+		atomic.OrUint64(&n48.present[c>>n48s], 1<<(c%n48m))
+	case Node256:
+		storeChild(&n.node256().children[c], child)
+	}
+	node.numChildren++
+}
+
+// insertShared shifts the keys and children greater than c to the right and puts c and child in the hole,
+// numChildren are in use
+func insertShared[V any](keys []byte, children []*artNode[V], numChildren int, c byte, child *artNode[V]) {
+	i := numChildren
+	for ; i > 0 && keys[i-1] > c; i-- {
+		storeKey(keys, i, keys[i-1])
+		storeChild(&children[i], children[i-1])
+	}
+	storeKey(keys, i, c)
+	storeChild(&children[i], child)
+}
+
+// removeSharedChild removes a child from a node locked by the caller which does not shrink or collapse
+func removeSharedChild[V any](n *artNode[V], c byte, valid bool) {
+	node := n.node()
+	if !valid {
+		storeChild(&node.zeroChild, nil)
+		return
+	}
+
+	numChildren := int(node.numChildren)
+	switch n._type {
+	case Node4:
+		n4 := n.node4()
+		removeShared(n4.keys[:], n4.children[:], numChildren, c)
+		n4.present[numChildren-1] = 0
+	case Node16:
+		n16 := n.node16()
+		removeShared(n16.keys[:], n16.children[:], numChildren, c)
+		n16.present = 1<<(numChildren-1) - 1
+	case Node48:
+		n48 := n.node48()
+		atomic.AndUint64(&n48.present[c>>n48s], ^uint64(1<<(c%n48m)))
+		storeChild(&n48.children[n48.keys[c]], nil)
+		storeKey(n48.keys[:], int(c), 0)
+	case Node256:
+		storeChild(&n.node256().children[c], nil)
+	}
+	node.numChildren--
+}
+
+// removeShared shifts the keys and children after c to the left, numChildren are in use
+func removeShared[V any](keys []byte, children []*artNode[V], numChildren int, c byte) {
+	i := 0
+	for keys[i] != c {
+		i++
+	}
+	for ; i < numChildren-1; i++ {
+		storeKey(keys, i, keys[i+1])
+		storeChild(&children[i], children[i+1])
+	}
+	storeKey(keys, numChildren-1, 0)
+	storeChild(&children[numChildren-1], nil)
+}
+
+// lockedClone copies a node locked by the caller, the copy is unlocked
+func lockedClone[V any](n *artNode[V]) *artNode[V] {
+	return n.clone()
+}
+
+// loadKey loads keys[i] of a node shared by goroutines, keys follow the child pointers
+// of a node, so the words holding them are aligned
+func loadKey(keys []byte, i int) byte {
+	word := atomic.LoadUint32((*uint32)(unsafe.Pointer(&keys[i&^3])))
+	return (*[4]byte)(unsafe.Pointer(&word))[i&3]
+}
+
+// storeKey stores keys[i] of a node locked by the caller
+func storeKey(keys []byte, i int, c byte) {
+	addr := (*uint32)(unsafe.Pointer(&keys[i&^3]))
+	word := atomic.LoadUint32(addr)
+	(*[4]byte)(unsafe.Pointer(&word))[i&3] = c
+	atomic.StoreUint32(addr, word)
+}
+
+func loadChild[V any](slot **artNode[V]) *artNode[V] {
+	return (*artNode[V])(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(slot))))
+}
+
+func storeChild[V any](slot **artNode[V], n *artNode[V]) {
+	atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(slot)), unsafe.Pointer(n))
+}
+
+// readLock waits until the lock word is unlocked and returns its version, false if the node is obsolete
+func readLock(version *uint64) (uint64, bool) {
+	for {
+		v := atomic.LoadUint64(version)
+		if v&lockedBit != 0 {
+			runtime.Gosched()
+			continue
+		}
+		return v, v&obsoleteBit == 0
+	}
+}
+
+// checkVersion reports whether the lock word has not changed since v was read
+func checkVersion(version *uint64, v uint64) bool {
+	return atomic.LoadUint64(version) == v
+}
+
+// upgradeLock locks the lock word if it has not changed since v was read
+func upgradeLock(version *uint64, v uint64) bool {
+	return atomic.CompareAndSwapUint64(version, v, v+lockedBit)
+}
+
+// unlock clears the lock bit and bumps the version
+func unlock(version *uint64) {
+	atomic.AddUint64(version, lockedBit)
+}
+
+// unlockObsolete unlocks a replaced node and marks it obsolete
+func unlockObsolete(version *uint64) {
+	atomic.AddUint64(version, lockedBit+obsoleteBit)
+}
@@ -0,0 +1,177 @@
+package art
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentTree(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3", "this:key:has:a:long:common:prefix", "this"}
+	for i := 0; i < node256Max; i++ {
+		keys = append(keys, string([]byte{'z', byte(i)}))
+	}
+
+	tree := NewConcurrent[string]()
+	for _, k := range keys {
+		_, updated := tree.Insert(Key(k), k)
+		assert.False(t, updated, k)
+	}
+	old, updated := tree.Insert(Key("api"), "new")
+	assert.True(t, updated)
+	assert.Equal(t, "api", old)
+	tree.Insert(Key("api"), "api")
+	assert.Equal(t, len(keys), tree.Size())
+
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	assert.Equal(t, sorted, tree.ForEachKeyPrefix(nil))
+	assert.Equal(t, []string{"api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz"}, tree.ForEachKeyPrefix(Key("api")))
+	assert.Equal(t, []string{"this:key:has:a:long:common:prefix", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2"}, tree.ForEachKeyPrefix(Key("this:key:has:a:long:c")))
+	assert.Empty(t, tree.ForEachKeyPrefix(Key("this:key:has:a:short")))
+
+	for _, k := range []string{"ap", "api.", "this:key:has:a:long:common:prefiz:1", "z"} {
+		assert.False(t, tree.Contains(Key(k)), k)
+	}
+
+	for i, k := range keys {
+		if i%2 == 0 {
+			v, deleted := tree.Delete(Key(k))
+			assert.True(t, deleted, k)
+			assert.Equal(t, k, v)
+		}
+	}
+	_, deleted := tree.Delete(Key("missing"))
+	assert.False(t, deleted)
+
+	for i, k := range keys {
+		v, found := tree.Search(Key(k))
+		assert.Equal(t, i%2 != 0, found, k)
+		if found {
+			assert.Equal(t, k, v)
+		}
+	}
+	assert.Equal(t, len(keys)/2, tree.Size())
+
+	for i, k := range keys {
+		if i%2 != 0 {
+			tree.Delete(Key(k))
+		}
+	}
+	assert.Equal(t, 0, tree.Size())
+	assert.Nil(t, tree.root)
+}
+
+func TestConcurrentTreeMixedWorkload(t *testing.T) {
+	const writers, readers, perWriter = 8, 4, 2000
+
+	tree := NewConcurrent[int]()
+	key := func(w, i int) Key {
+		// writers share prefixes so that they contend on the same nodes
+		return Key(fmt.Sprintf("k:%03d:%d", i%300, w*perWriter+i))
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if v, found := tree.Search(key(i%writers, i%perWriter)); found {
+					assert.Equal(t, (i%writers)*perWriter+i%perWriter, v)
+				}
+				if i%100 == 0 {
+					keys := tree.ForEachKeyPrefix(Key(fmt.Sprintf("k:%03d:", i%300)))
+					assert.True(t, sort.StringsAreSorted(keys))
+				}
+			}
+		}(r)
+	}
+
+	var writersWg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersWg.Add(1)
+		go func(w int) {
+			defer writersWg.Done()
+			for i := 0; i < perWriter; i++ {
+				tree.Insert(key(w, i), w*perWriter+i)
+			}
+			for i := 0; i < perWriter; i += 2 {
+				_, deleted := tree.Delete(key(w, i))
+				assert.True(t, deleted)
+			}
+			// the same keys are written by all writers
+			for i := 0; i < 100; i++ {
+				tree.Insert(Key(fmt.Sprintf("shared:%d", i)), i)
+			}
+		}(w)
+	}
+	writersWg.Wait()
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, writers*perWriter/2+100, tree.Size())
+	for w := 0; w < writers; w++ {
+		for i := 0; i < perWriter; i++ {
+			v, found := tree.Search(key(w, i))
+			assert.Equal(t, i%2 == 1, found)
+			if found {
+				assert.Equal(t, w*perWriter+i, v)
+			}
+		}
+	}
+	assert.Len(t, tree.ForEachKeyPrefix(Key("shared:")), 100)
+	assert.Len(t, tree.ForEachKeyPrefix(nil), writers*perWriter/2+100)
+}
+
+func TestConcurrentTreeInPlace(t *testing.T) {
+	// keys are loaded by words, which needs them aligned
+	assert.Zero(t, unsafe.Offsetof(node4[int]{}.keys)%4)
+	assert.Zero(t, unsafe.Offsetof(node16[int]{}.keys)%4)
+	assert.Zero(t, unsafe.Offsetof(node48[int]{}.keys)%4)
+
+	tree := NewConcurrent[int]()
+	insert := func(keys ...string) {
+		for _, k := range keys {
+			tree.Insert(Key(k), 0)
+		}
+	}
+	insert("b", "d")
+	root := tree.root
+
+	// children are added and removed in place until the node grows or shrinks
+	insert("c", "a", "")
+	assert.Same(t, root, tree.root)
+	assert.Equal(t, []string{"", "a", "b", "c", "d"}, tree.ForEachKeyPrefix(nil))
+	tree.Delete(Key("c"))
+	tree.Delete(Key(""))
+	assert.Same(t, root, tree.root)
+
+	insert("c", "e")
+	assert.NotSame(t, root, tree.root)
+	assert.Equal(t, Node16, tree.root.Type())
+	root = tree.root
+	for c := 'f'; c <= 'z'; c++ {
+		insert(string(c))
+	}
+	assert.Equal(t, Node48, tree.root.Type())
+	root = tree.root
+	insert("A", "B")
+	tree.Delete(Key("z"))
+	assert.Same(t, root, tree.root)
+	for _, k := range []string{"A", "B", "y", "x", "w", "v", "u", "t", "s", "r", "q"} {
+		tree.Delete(Key(k))
+	}
+	assert.Equal(t, Node16, tree.root.Type())
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p"}, tree.ForEachKeyPrefix(nil))
+}
@@ -0,0 +1,173 @@
+package art
+
+import "bytes"
+
+func (t *tree[V]) Cursor() *Cursor[V] {
+	return &Cursor[V]{tree: t}
+}
+
+// Valid reports whether the cursor is positioned at a key.
+func (c *Cursor[V]) Valid() bool {
+	return c != nil && c.leaf != nil
+}
+
+func (c *Cursor[V]) Key() Key {
+	if !c.Valid() {
+		return nil
+	}
+	return c.leaf.Key()
+}
+
+func (c *Cursor[V]) Value() V {
+	if !c.Valid() {
+		var zero V
+		return zero
+	}
+	return c.leaf.Value()
+}
+
+// SeekFirst moves the cursor to the least key.
+func (c *Cursor[V]) SeekFirst() bool {
+	c.reset()
+	c.descendFirst(c.tree.root)
+	return c.Valid()
+}
+
+// SeekLast moves the cursor to the greatest key.
+func (c *Cursor[V]) SeekLast() bool {
+	c.reset()
+	c.descendLast(c.tree.root)
+	return c.Valid()
+}
+
+// Seek moves the cursor to the least key which is greater than or equal to key.
+func (c *Cursor[V]) Seek(key Key) bool {
+	c.reset()
+
+	curr := c.tree.root
+	depth := uint32(0)
+	for curr != nil {
+		if curr.isLeaf() {
+			c.leaf = curr
+			if bytes.Compare(curr.leaf().key, key) < 0 {
+				return c.Next()
+			}
+			return true
+		}
+
+		node := curr.node()
+		if node.prefixLen > 0 {
+			switch compareBound(curr.prefixBytes(depth), key, depth) {
+			case 1:
+				// every key under curr is greater than key
+				c.descendFirst(curr)
+				return true
+			case -1:
+				// every key under curr is less than key
+				c.descendLast(curr)
+				return c.Next()
+			}
+			depth += node.prefixLen
+		}
+
+		if !key.valid(int(depth)) {
+			// key ends here, no key under curr is less than it
+			c.descendFirst(curr)
+			return true
+		}
+
+		pos, child := curr.childNotLess(key[depth])
+		if child == nil {
+			c.descendLast(curr)
+			return c.Next()
+		}
+
+		c.depth = append(c.depth, &iteratorLevel[V]{curr, pos})
+		if curr.keyAt(pos) != key[depth] {
+			c.descendFirst(child)
+			return true
+		}
+		curr = child
+		depth++
+	}
+
+	return false
+}
+
+// Next moves the cursor to the next key, the cursor becomes invalid after the greatest key.
+func (c *Cursor[V]) Next() bool {
+	if !c.Valid() {
+		return false
+	}
+
+	for len(c.depth) > 0 {
+		level := c.depth[len(c.depth)-1]
+		if pos, child := level.node.childAfter(level.childIdx); child != nil {
+			level.childIdx = pos
+			c.descendFirst(child)
+			return true
+		}
+		c.depth = c.depth[:len(c.depth)-1]
+	}
+
+	c.leaf = nil
+	return false
+}
+
+// Prev moves the cursor to the previous key, the cursor becomes invalid before the least key.
+func (c *Cursor[V]) Prev() bool {
+	if !c.Valid() {
+		return false
+	}
+
+	for len(c.depth) > 0 {
+		level := c.depth[len(c.depth)-1]
+		if level.childIdx != nullIdx {
+			pos, child := level.node.childBefore(level.childIdx)
+			if child == nil {
+				child = level.node.node().zeroChild
+			}
+			if child != nil {
+				level.childIdx = pos
+				c.descendLast(child)
+				return true
+			}
+		}
+		c.depth = c.depth[:len(c.depth)-1]
+	}
+
+	c.leaf = nil
+	return false
+}
+
+func (c *Cursor[V]) reset() {
+	c.leaf = nil
+	c.depth = c.depth[:0]
+}
+
+// descendFirst moves the cursor to the least leaf under curr,
+// zeroChild has position nullIdx in the path.
+func (c *Cursor[V]) descendFirst(curr *artNode[V]) {
+	for curr != nil && !curr.isLeaf() {
+		pos, child := nullIdx, curr.node().zeroChild
+		if child == nil {
+			pos, child = curr.childAfter(nullIdx)
+		}
+		c.depth = append(c.depth, &iteratorLevel[V]{curr, pos})
+		curr = child
+	}
+	c.leaf = curr
+}
+
+// descendLast moves the cursor to the greatest leaf under curr
+func (c *Cursor[V]) descendLast(curr *artNode[V]) {
+	for curr != nil && !curr.isLeaf() {
+		pos, child := curr.childBefore(node256Max)
+		if child == nil {
+			pos, child = nullIdx, curr.node().zeroChild
+		}
+		c.depth = append(c.depth, &iteratorLevel[V]{curr, pos})
+		curr = child
+	}
+	c.leaf = curr
+}
@@ -0,0 +1,96 @@
+package art
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3"}
+	for i := 0; i < node256Max; i += 5 {
+		keys = append(keys, string([]byte{'x', byte(i)}))
+	}
+	for i := 0; i < node256Max; i += 2 {
+		keys = append(keys, string([]byte{'y', byte(i)}))
+	}
+	for i := 0; i < node256Max; i++ {
+		keys = append(keys, string([]byte{'z', byte(i)}))
+	}
+
+	tree := New[string]()
+	c := tree.Cursor()
+	assert.False(t, c.SeekFirst())
+	assert.False(t, c.Seek(Key("a")))
+
+	for _, k := range keys {
+		tree.Insert(Key(k), k)
+	}
+	sort.Strings(keys)
+
+	assert.True(t, c.SeekFirst())
+	for i, k := range keys {
+		assert.True(t, c.Valid())
+		assert.Equal(t, k, c.Key().String())
+		assert.Equal(t, k, c.Value())
+		assert.Equal(t, i < len(keys)-1, c.Next())
+	}
+	assert.False(t, c.Valid())
+	assert.False(t, c.Prev())
+
+	assert.True(t, c.SeekLast())
+	for i := len(keys) - 1; i >= 0; i-- {
+		assert.Equal(t, keys[i], c.Key().String())
+		assert.Equal(t, i > 0, c.Prev())
+	}
+	assert.False(t, c.Valid())
+	assert.Nil(t, c.Key())
+
+	probes := append([]string{"ab", "api.", "api.foo.bb", "az", "bb", "this:key:has:a:long:", "this:key:has:a:long:d", "this:key:has:a:long:common:prefix:10", "x\x03", "y\x03", "y\xff", "zz", "\x00"}, keys...)
+	for _, probe := range probes {
+		idx := sort.SearchStrings(keys, probe)
+		if !assert.Equal(t, idx < len(keys), c.Seek(Key(probe)), "%q", probe) || idx == len(keys) {
+			continue
+		}
+		assert.Equal(t, keys[idx], c.Key().String(), "%q", probe)
+
+		// walk a few steps both ways from the seek position
+		for i := idx + 1; i < idx+4 && i < len(keys); i++ {
+			assert.True(t, c.Next())
+			assert.Equal(t, keys[i], c.Key().String(), "%q", probe)
+		}
+		for i := min(uint32(idx+3), uint32(len(keys)-1)); i > 0 && int(i) > idx-4; i-- {
+			assert.True(t, c.Prev())
+			assert.Equal(t, keys[i-1], c.Key().String(), "%q", probe)
+		}
+	}
+}
+
+func TestCursorMergeJoin(t *testing.T) {
+	left, right := New[struct{}](), New[struct{}]()
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		left.Insert(Key(k), struct{}{})
+	}
+	for _, k := range []string{"b", "d", "dd", "f", "g"} {
+		right.Insert(Key(k), struct{}{})
+	}
+
+	joined := make([]string, 0)
+	lc, rc := left.Cursor(), right.Cursor()
+	lc.SeekFirst()
+	rc.SeekFirst()
+	for lc.Valid() && rc.Valid() {
+		switch l, r := lc.Key().String(), rc.Key().String(); {
+		case l == r:
+			joined = append(joined, l)
+			lc.Next()
+			rc.Next()
+		case l < r:
+			lc.Seek(rc.Key())
+		default:
+			rc.Seek(lc.Key())
+		}
+	}
+	assert.Equal(t, []string{"b", "d", "f"}, joined)
+}
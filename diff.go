@@ -0,0 +1,183 @@
+package art
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Diff calls callback with the changes from a to b in key order until callback returns false,
+// values are compared by reflect.DeepEqual. Subtrees shared by a and b are skipped,
+// so versions of an ImmutableTree are compared in time proportional to their changes.
+func Diff[V any](a, b Reader[V], callback func(change Change[V]) bool) {
+	d := &differ[V]{callback: callback}
+
+	ra, okA := a.(rooted[V])
+	rb, okB := b.(rooted[V])
+	if !okA || !okB {
+		d.diffSorted(a, b)
+		return
+	}
+	d.diff(setView[V]{n: ra.rootNode()}, setView[V]{n: rb.rootNode()}, 0)
+}
+
+func (t DiffType) String() string {
+	return []string{"Added", "Removed", "Changed"}[t]
+}
+
+// diff walks x and y whose prefixes start at depth in lockstep like setMerger.combine,
+// it returns false once callback stops it.
+func (d *differ[V]) diff(x, y setView[V], depth uint32) bool {
+	switch {
+	case x == y:
+		return true
+	case x.n == nil:
+		return d.all(y.n, DiffAdded)
+	case y.n == nil:
+		return d.all(x.n, DiffRemoved)
+	case x.n.isLeaf():
+		return d.diffLeaf(x.n.leaf(), y.n, false)
+	case y.n.isLeaf():
+		return d.diffLeaf(y.n.leaf(), x.n, true)
+	}
+
+	rx, ry := x.rest(depth), y.rest(depth)
+	l := 0
+	for l < len(rx) && l < len(ry) && rx[l] == ry[l] {
+		l++
+	}
+	end := depth + uint32(l)
+
+	xZero, xChildren := x.branch(rx, l)
+	yZero, yChildren := y.branch(ry, l)
+	if !d.diff(xZero, yZero, end+1) {
+		return false
+	}
+
+	i, j := 0, 0
+	for i < len(xChildren) || j < len(yChildren) {
+		var next bool
+		switch {
+		case j == len(yChildren) || i < len(xChildren) && xChildren[i].c < yChildren[j].c:
+			next = d.diff(xChildren[i].v, setView[V]{}, end+1)
+			i++
+		case i == len(xChildren) || yChildren[j].c < xChildren[i].c:
+			next = d.diff(setView[V]{}, yChildren[j].v, end+1)
+			j++
+		default:
+			next = d.diff(xChildren[i].v, yChildren[j].v, end+1)
+			i++
+			j++
+		}
+		if !next {
+			return false
+		}
+	}
+	return true
+}
+
+// diffLeaf compares the single key of l with the subtree of n,
+// reversed tells that l is in the new tree and n in the old one
+func (d *differ[V]) diffLeaf(l *leaf[V], n *artNode[V], reversed bool) bool {
+	only, other := DiffRemoved, DiffAdded
+	if reversed {
+		only, other = DiffAdded, DiffRemoved
+	}
+
+	pending := true
+	return walkLeaves(n, func(ln *leaf[V]) bool {
+		if pending {
+			switch cmp := bytes.Compare(l.key, ln.key); {
+			case cmp == 0:
+				pending = false
+				old, new := l, ln
+				if reversed {
+					old, new = ln, l
+				}
+				return d.changed(old, new)
+			case cmp < 0:
+				pending = false
+				if !d.emit(only, l) {
+					return false
+				}
+			}
+		}
+		return d.emit(other, ln)
+	}) && (!pending || d.emit(only, l))
+}
+
+// all reports every key under n as added or removed
+func (d *differ[V]) all(n *artNode[V], op DiffType) bool {
+	return walkLeaves(n, func(l *leaf[V]) bool {
+		return d.emit(op, l)
+	})
+}
+
+func (d *differ[V]) emit(op DiffType, l *leaf[V]) bool {
+	change := Change[V]{Type: op, Key: l.key}
+	if op == DiffAdded {
+		change.New = l.value
+	} else {
+		change.Old = l.value
+	}
+	return d.callback(change)
+}
+
+func (d *differ[V]) changed(old, new *leaf[V]) bool {
+	if reflect.DeepEqual(old.value, new.value) {
+		return true
+	}
+	return d.callback(Change[V]{Type: DiffChanged, Key: new.key, Old: old.value, New: new.value})
+}
+
+// diffSorted compares the sorted keys of trees which cannot be walked by nodes
+func (d *differ[V]) diffSorted(a, b Reader[V]) {
+	ia, ib := a.Iterator(), b.Iterator()
+	na, _ := ia.Next()
+	nb, _ := ib.Next()
+	for na != nil || nb != nil {
+		cmp := 0
+		switch {
+		case na == nil:
+			cmp = 1
+		case nb == nil:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(na.Key(), nb.Key())
+		}
+
+		next := true
+		switch {
+		case cmp < 0:
+			next = d.callback(Change[V]{Type: DiffRemoved, Key: na.Key(), Old: na.Value()})
+			na, _ = ia.Next()
+		case cmp > 0:
+			next = d.callback(Change[V]{Type: DiffAdded, Key: nb.Key(), New: nb.Value()})
+			nb, _ = ib.Next()
+		default:
+			if old, new := na.Value(), nb.Value(); !reflect.DeepEqual(old, new) {
+				next = d.callback(Change[V]{Type: DiffChanged, Key: nb.Key(), Old: old, New: new})
+			}
+			na, _ = ia.Next()
+			nb, _ = ib.Next()
+		}
+		if !next {
+			return
+		}
+	}
+}
+
+// walkLeaves calls f with leaves under n in order until f returns false
+func walkLeaves[V any](n *artNode[V], f func(*leaf[V]) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.isLeaf() {
+		return f(n.leaf())
+	}
+	if !walkLeaves(n.node().zeroChild, f) {
+		return false
+	}
+	return n.forEachChild(func(_ byte, child *artNode[V]) bool {
+		return walkLeaves(child, f)
+	})
+}
@@ -0,0 +1,101 @@
+package art
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for round := 0; round < 200; round++ {
+		old := randomSetKeys(r, r.Intn(60))
+		a := NewImmutable[[]int]()
+		for k, v := range old {
+			a, _, _ = a.Insert(Key(k), []int{v})
+		}
+
+		// b shares all unmodified nodes with a
+		b := a
+		new := map[string]int{}
+		for k, v := range old {
+			new[k] = v
+		}
+		for k, v := range randomSetKeys(r, r.Intn(10)) {
+			switch r.Intn(3) {
+			case 0:
+				b, _, _ = b.Delete(Key(k))
+				delete(new, k)
+			case 1:
+				if _, ok := new[k]; ok {
+					v = new[k]
+				}
+				fallthrough
+			default:
+				b, _, _ = b.Insert(Key(k), []int{v})
+				new[k] = v
+			}
+		}
+
+		var expected []Change[[]int]
+		for k, v := range old {
+			if nv, ok := new[k]; !ok {
+				expected = append(expected, Change[[]int]{Type: DiffRemoved, Key: Key(k), Old: []int{v}})
+			} else if nv != v {
+				expected = append(expected, Change[[]int]{Type: DiffChanged, Key: Key(k), Old: []int{v}, New: []int{nv}})
+			}
+		}
+		for k, v := range new {
+			if _, ok := old[k]; !ok {
+				expected = append(expected, Change[[]int]{Type: DiffAdded, Key: Key(k), New: []int{v}})
+			}
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			return bytes.Compare(expected[i].Key, expected[j].Key) < 0
+		})
+
+		var frozen bytes.Buffer
+		_, err := WriteFrozen[[]int](&frozen, b, nil)
+		assert.NoError(t, err)
+		fb, err := NewFrozen[[]int](frozen.Bytes(), nil)
+		assert.NoError(t, err)
+
+		// b is walked by nodes as an ImmutableTree and by keys as a FrozenTree
+		for _, rb := range []Reader[[]int]{b, fb} {
+			var changes []Change[[]int]
+			Diff[[]int](a, rb, func(c Change[[]int]) bool {
+				// the empty key may be nil
+				c.Key = Key(c.Key.String())
+				changes = append(changes, c)
+				return true
+			})
+			if len(expected) == 0 {
+				assert.Empty(t, changes)
+			} else {
+				assert.Equal(t, expected, changes)
+			}
+		}
+	}
+}
+
+func TestDiffStop(t *testing.T) {
+	a, b := New[int](), New[int]()
+	for _, k := range []string{"a", "b", "c"} {
+		b.Insert(Key(k), 1)
+	}
+
+	calls := 0
+	Diff[int](a, b, func(c Change[int]) bool {
+		calls++
+		return false
+	})
+	assert.Equal(t, 1, calls)
+
+	Diff[int](b, b, func(c Change[int]) bool {
+		t.Fatal("identical trees have no change")
+		return true
+	})
+}
@@ -0,0 +1,110 @@
+package art
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (t *tree[V]) Dump(w io.Writer, format DumpFormat) error {
+	if format != DumpASCII && format != DumpDOT {
+		return ErrDumpFormat
+	}
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case DumpDOT:
+		fmt.Fprintln(bw, "digraph art {")
+		fmt.Fprintln(bw, "\tnode [shape=box];")
+		id := 0
+		dumpDOT(bw, t.root, &id)
+		fmt.Fprintln(bw, "}")
+	default:
+		if t.root == nil {
+			fmt.Fprintln(bw, "<empty>")
+		} else {
+			fmt.Fprintln(bw, describe(t.root))
+			dumpASCII(bw, t.root, "")
+		}
+	}
+	return bw.Flush()
+}
+
+// describe returns the type and the prefix of an inner node or the key of a leaf,
+// a prefix longer than MaxPrefixLen is shown by its stored bytes
+func describe[V any](n *artNode[V]) string {
+	if n.isLeaf() {
+		return fmt.Sprintf("Leaf %q", n.leaf().key)
+	}
+
+	node := n.node()
+	stored := node.prefix[:min(node.prefixLen, MaxPrefixLen)]
+	ellipsis := ""
+	if node.prefixLen > MaxPrefixLen {
+		ellipsis = "..."
+	}
+	return fmt.Sprintf("%s prefix=%q%s len=%d keys=%d", n._type, stored, ellipsis, node.prefixLen, node.numKeys)
+}
+
+// dumpedChildren returns the labels and the children of n in order, zeroChild first
+func dumpedChildren[V any](n *artNode[V]) ([]string, []*artNode[V]) {
+	var labels []string
+	var children []*artNode[V]
+	if zeroChild := n.node().zeroChild; zeroChild != nil {
+		labels = append(labels, "zero")
+		children = append(children, zeroChild)
+	}
+	n.forEachChild(func(c byte, child *artNode[V]) bool {
+		labels = append(labels, fmt.Sprintf("%q", c))
+		children = append(children, child)
+		return true
+	})
+	return labels, children
+}
+
+func dumpASCII[V any](w io.Writer, n *artNode[V], indent string) {
+	if n.isLeaf() {
+		return
+	}
+
+	labels, children := dumpedChildren(n)
+	for i, child := range children {
+		branch, next := "|-- ", "|   "
+		if i == len(children)-1 {
+			branch, next = "`-- ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s: %s\n", indent, branch, labels[i], describe(child))
+		dumpASCII(w, child, indent+next)
+	}
+}
+
+// dumpDOT writes n and its subtree as nodes numbered from id, it returns the name of n
+func dumpDOT[V any](w io.Writer, n *artNode[V], id *int) string {
+	if n == nil {
+		return ""
+	}
+
+	name := fmt.Sprintf("n%d", *id)
+	*id++
+	shape := ""
+	if n.isLeaf() {
+		shape = " shape=ellipse"
+	}
+	fmt.Fprintf(w, "\t%s [label=%s%s];\n", name, dotString(describe(n)), shape)
+
+	if n.isLeaf() {
+		return name
+	}
+	labels, children := dumpedChildren(n)
+	for i, child := range children {
+		childName := dumpDOT(w, child, id)
+		fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", name, childName, dotString(labels[i]))
+	}
+	return name
+}
+
+// dotString quotes s as a DOT string, backslashes of Go escapes are kept literally
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
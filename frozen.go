@@ -0,0 +1,536 @@
+package art
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+/*
+	A frozen tree is laid out as
+
+		magic "ARTF" | uint32 version | nodes | uint64 size | uint64 root offset | magic "ARTF"
+
+	integers are little endian, a child is the offset of its node, 0 is no child.
+	Nodes are written children first, a leaf is
+
+		type | uint32 key length | uint32 value length | key | value
+
+	an inner node is
+
+		type | uint16 numChildren | uint32 prefixLen | prefix | uint64 numKeys | uint64 zeroChild | children
+
+	children of Node4 and Node16 are numChildren sorted key bytes followed by numChildren offsets,
+	Node48 has 256 indexes, index i+1 for a key byte of child i, 0 for none, followed by numChildren offsets,
+	Node256 has 256 offsets.
+*/
+
+const (
+	frozenHeaderLen  = 8
+	frozenTrailerLen = 20
+
+	// offsets in a leaf
+	frozenLeafKeyLen   = 1
+	frozenLeafValueLen = 5
+	frozenLeafKey      = 9
+
+	// offsets in an inner node
+	frozenNumChildren = 1
+	frozenPrefixLen   = 3
+	frozenPrefix      = 7
+	frozenNumKeys     = frozenPrefix + MaxPrefixLen
+	frozenZeroChild   = frozenNumKeys + 8
+	frozenChildren    = frozenZeroChild + 8
+)
+
+// WriteFrozen writes the keys of r in the layout of a frozen tree, values are encoded by codec,
+// nil means GobCodec. It returns the number of bytes written.
+func WriteFrozen[V any](w io.Writer, r Reader[V], codec Codec[V]) (int64, error) {
+	if codec == nil {
+		codec = GobCodec[V]{}
+	}
+
+	keys := make([]Key, 0, r.Size())
+	values := make([][]byte, 0, r.Size())
+	for it := r.Iterator(); it.HasNext(); {
+		n, err := it.Next()
+		if err != nil {
+			return 0, err
+		}
+		value, err := codec.Marshal(n.Value())
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, n.Key())
+		values = append(values, value)
+	}
+
+	fw := &frozenWriter{w: bufio.NewWriter(w)}
+	fw.w.WriteString(frozenMagic)
+	fw.uint32(frozenVersion)
+	fw.n = frozenHeaderLen
+
+	root := uint64(0)
+	if len(keys) > 0 {
+		root = fw.node(keys, values, 0)
+	}
+	fw.uint64(uint64(len(keys)))
+	fw.uint64(root)
+	fw.w.WriteString(frozenMagic)
+	fw.n += frozenTrailerLen
+
+	return fw.n, fw.w.Flush()
+}
+
+type frozenWriter struct {
+	w   *bufio.Writer
+	n   int64
+	buf [8]byte
+}
+
+func (fw *frozenWriter) uint16(x uint16) {
+	binary.LittleEndian.PutUint16(fw.buf[:], x)
+	fw.w.Write(fw.buf[:2])
+}
+
+func (fw *frozenWriter) uint32(x uint32) {
+	binary.LittleEndian.PutUint32(fw.buf[:], x)
+	fw.w.Write(fw.buf[:4])
+}
+
+func (fw *frozenWriter) uint64(x uint64) {
+	binary.LittleEndian.PutUint64(fw.buf[:], x)
+	fw.w.Write(fw.buf[:8])
+}
+
+// node writes the subtree of sorted keys after its children and returns its offset,
+// its layout is the one buildSorted gives the keys.
+func (fw *frozenWriter) node(keys []Key, values [][]byte, depth uint32) uint64 {
+	if len(keys) == 1 {
+		offset := uint64(fw.n)
+		fw.w.WriteByte(byte(Leaf))
+		fw.uint32(uint32(len(keys[0])))
+		fw.uint32(uint32(len(values[0])))
+		fw.w.Write(keys[0])
+		fw.w.Write(values[0])
+		fw.n += int64(frozenLeafKey + len(keys[0]) + len(values[0]))
+		return offset
+	}
+
+	end, zero, groups := partitionSorted(keys, depth)
+	zeroChild := uint64(0)
+	if zero {
+		zeroChild = fw.node(keys[:1], values[:1], end)
+	}
+	numChildren := len(groups) - 1
+	labels := make([]byte, numChildren)
+	children := make([]uint64, numChildren)
+	for i := range children {
+		start, stop := groups[i], groups[i+1]
+		labels[i] = keys[start][end]
+		children[i] = fw.node(keys[start:stop], values[start:stop], end+1)
+	}
+
+	offset := uint64(fw.n)
+	var _type NodeType
+	switch {
+	case numChildren <= node4Max:
+		_type = Node4
+	case numChildren <= node16Max:
+		_type = Node16
+	case numChildren <= node48Max:
+		_type = Node48
+	default:
+		_type = Node256
+	}
+
+	var prefix [MaxPrefixLen]byte
+	copy(prefix[:], keys[0][depth:end])
+	fw.w.WriteByte(byte(_type))
+	fw.uint16(uint16(numChildren))
+	fw.uint32(end - depth)
+	fw.w.Write(prefix[:])
+	fw.uint64(uint64(len(keys)))
+	fw.uint64(zeroChild)
+	fw.n += frozenChildren
+
+	switch _type {
+	case Node4, Node16:
+		fw.w.Write(labels)
+		fw.n += int64(numChildren)
+		for _, child := range children {
+			fw.uint64(child)
+		}
+		fw.n += int64(8 * numChildren)
+	case Node48:
+		var index [node256Max]byte
+		for i, c := range labels {
+			index[c] = byte(i + 1)
+		}
+		fw.w.Write(index[:])
+		for _, child := range children {
+			fw.uint64(child)
+		}
+		fw.n += int64(node256Max + 8*numChildren)
+	case Node256:
+		var all [node256Max]uint64
+		for i, c := range labels {
+			all[c] = children[i]
+		}
+		for _, child := range all {
+			fw.uint64(child)
+		}
+		fw.n += 8 * node256Max
+	}
+	return offset
+}
+
+// NewFrozen returns a tree served from data written by WriteFrozen, values are decoded by codec,
+// nil means GobCodec. Only the header and the trailer are checked, data must not be modified.
+func NewFrozen[V any](data []byte, codec Codec[V]) (*FrozenTree[V], error) {
+	if len(data) < frozenHeaderLen+frozenTrailerLen ||
+		string(data[:4]) != frozenMagic ||
+		binary.LittleEndian.Uint32(data[4:]) != frozenVersion ||
+		string(data[len(data)-4:]) != frozenMagic {
+		return nil, ErrFrozenFormat
+	}
+	if codec == nil {
+		codec = GobCodec[V]{}
+	}
+
+	trailer := data[len(data)-frozenTrailerLen:]
+	t := &FrozenTree[V]{
+		data:  data,
+		size:  int(binary.LittleEndian.Uint64(trailer)),
+		root:  binary.LittleEndian.Uint64(trailer[8:]),
+		codec: codec,
+	}
+	if t.root != 0 && t.root < frozenHeaderLen || t.root >= uint64(len(data)-frozenTrailerLen) || (t.root == 0) != (t.size == 0) {
+		return nil, ErrFrozenFormat
+	}
+	return t, nil
+}
+
+// Close releases the mapping of a tree opened by OpenFrozen, the tree must not be used after.
+func (t *FrozenTree[V]) Close() error {
+	if t.unmap == nil {
+		return nil
+	}
+	unmap := t.unmap
+	t.unmap, t.data = nil, nil
+	return unmap()
+}
+
+func (t *FrozenTree[V]) Size() int {
+	return t.size
+}
+
+// Search returns the value stored under key, it is the zero value if the codec fails,
+// Get reports the error.
+func (t *FrozenTree[V]) Search(key Key) (V, bool) {
+	value, found, _ := t.Get(key)
+	return value, found
+}
+
+// Get returns the value stored under key and the error of the codec decoding it,
+// a key is found whether or not its value decodes.
+func (t *FrozenTree[V]) Get(key Key) (V, bool, error) {
+	var zero V
+	n := t.search(key)
+	if n == 0 {
+		return zero, false, nil
+	}
+	value, err := t.node(n).DecodeValue()
+	return value, true, err
+}
+
+func (t *FrozenTree[V]) Contains(key Key) bool {
+	return t.search(key) != 0
+}
+
+// search returns the offset of the leaf of key, prefixes are compared optimistically like tree.search does
+func (t *FrozenTree[V]) search(key Key) uint64 {
+	curr := t.root
+	depth := uint32(0)
+	for curr != 0 {
+		if t.isLeaf(curr) {
+			if bytes.Equal(t.leafKey(curr), key) {
+				return curr
+			}
+			return 0
+		}
+
+		if prefixLen := t.prefixLen(curr); prefixLen > 0 {
+			p := t.prefix(curr)
+			if !bytes.HasPrefix(key[min(depth, uint32(len(key))):], p) {
+				return 0
+			}
+			depth += prefixLen
+		}
+
+		if key.valid(int(depth)) {
+			curr = t.child(curr, key[depth])
+		} else {
+			curr = t.zeroChild(curr)
+		}
+		depth++
+	}
+	return 0
+}
+
+func (t *FrozenTree[V]) ForEachKeyPrefix(prefix Key) []string {
+	keys := make([]string, 0)
+	collect := func(n uint64) bool {
+		if key := t.leafKey(n); bytes.HasPrefix(key, prefix) {
+			keys = append(keys, key.String())
+		}
+		return true
+	}
+
+	curr := t.root
+	depth := uint32(0)
+	for curr != 0 {
+		if t.isLeaf(curr) || depth >= uint32(len(prefix)) {
+			t.walk(curr, collect)
+			break
+		}
+
+		if prefixLen := t.prefixLen(curr); prefixLen > 0 {
+			p := t.prefix(curr)
+			rest := prefix[depth:]
+			if len(rest) < len(p) {
+				p = p[:len(rest)]
+			}
+			if !bytes.HasPrefix(rest, p) {
+				break
+			}
+			if depth+prefixLen >= uint32(len(prefix)) {
+				t.walk(curr, collect)
+				break
+			}
+			depth += prefixLen
+		}
+
+		curr = t.child(curr, prefix[depth])
+		depth++
+	}
+	return keys
+}
+
+// walk calls f with leaves under n in order
+func (t *FrozenTree[V]) walk(n uint64, f func(uint64) bool) bool {
+	if n == 0 {
+		return true
+	}
+	if t.isLeaf(n) {
+		return f(n)
+	}
+	if !t.walk(t.zeroChild(n), f) {
+		return false
+	}
+	return t.forEachChild(n, func(child uint64) bool {
+		return t.walk(child, f)
+	})
+}
+
+// forEachChild calls f with children of an inner node in order, zeroChild excluded
+func (t *FrozenTree[V]) forEachChild(n uint64, f func(uint64) bool) bool {
+	children := t.data[n+frozenChildren:]
+	numChildren := int(binary.LittleEndian.Uint16(t.data[n+frozenNumChildren:]))
+	switch NodeType(t.data[n]) {
+	case Node4, Node16:
+		for i := 0; i < numChildren; i++ {
+			if !f(binary.LittleEndian.Uint64(children[numChildren+8*i:])) {
+				return false
+			}
+		}
+	case Node48:
+		for c := 0; c < node256Max; c++ {
+			if i := int(children[c]); i > 0 && !f(binary.LittleEndian.Uint64(children[node256Max+8*(i-1):])) {
+				return false
+			}
+		}
+	case Node256:
+		for c := 0; c < node256Max; c++ {
+			if child := binary.LittleEndian.Uint64(children[8*c:]); child != 0 && !f(child) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// child returns the offset of the child of an inner node for key byte c
+func (t *FrozenTree[V]) child(n uint64, c byte) uint64 {
+	children := t.data[n+frozenChildren:]
+	numChildren := int(binary.LittleEndian.Uint16(t.data[n+frozenNumChildren:]))
+	switch NodeType(t.data[n]) {
+	case Node4, Node16:
+		if i := bytes.IndexByte(children[:numChildren], c); i >= 0 {
+			return binary.LittleEndian.Uint64(children[numChildren+8*i:])
+		}
+	case Node48:
+		if i := int(children[c]); i > 0 {
+			return binary.LittleEndian.Uint64(children[node256Max+8*(i-1):])
+		}
+	case Node256:
+		return binary.LittleEndian.Uint64(children[8*int(c):])
+	}
+	return 0
+}
+
+func (t *FrozenTree[V]) isLeaf(n uint64) bool {
+	return NodeType(t.data[n]) == Leaf
+}
+
+func (t *FrozenTree[V]) zeroChild(n uint64) uint64 {
+	return binary.LittleEndian.Uint64(t.data[n+frozenZeroChild:])
+}
+
+func (t *FrozenTree[V]) prefixLen(n uint64) uint32 {
+	return binary.LittleEndian.Uint32(t.data[n+frozenPrefixLen:])
+}
+
+// prefix returns the stored bytes of the prefix, at most MaxPrefixLen
+func (t *FrozenTree[V]) prefix(n uint64) []byte {
+	start := n + frozenPrefix
+	return t.data[start : start+uint64(min(t.prefixLen(n), MaxPrefixLen))]
+}
+
+func (t *FrozenTree[V]) leafKey(n uint64) Key {
+	keyLen := uint64(binary.LittleEndian.Uint32(t.data[n+frozenLeafKeyLen:]))
+	start := n + frozenLeafKey
+	return t.data[start : start+keyLen : start+keyLen]
+}
+
+func (t *FrozenTree[V]) leafValue(n uint64) []byte {
+	keyLen := uint64(binary.LittleEndian.Uint32(t.data[n+frozenLeafKeyLen:]))
+	valueLen := uint64(binary.LittleEndian.Uint32(t.data[n+frozenLeafValueLen:]))
+	start := n + frozenLeafKey + keyLen
+	return t.data[start : start+valueLen : start+valueLen]
+}
+
+func (t *FrozenTree[V]) node(offset uint64) *frozenNode[V] {
+	return &frozenNode[V]{tree: t, offset: offset}
+}
+
+func (n *frozenNode[V]) Type() NodeType {
+	return NodeType(n.tree.data[n.offset])
+}
+
+// Key returns the key of a leaf, it refers to the data of the tree
+func (n *frozenNode[V]) Key() Key {
+	if !n.tree.isLeaf(n.offset) {
+		return nil
+	}
+	return n.tree.leafKey(n.offset)
+}
+
+// Value decodes the value of a leaf, it is the zero value if the codec fails,
+// DecodeValue reports the error.
+func (n *frozenNode[V]) Value() V {
+	value, _ := n.DecodeValue()
+	return value
+}
+
+// DecodeValue decodes the value of a leaf, it returns the error of the codec
+func (n *frozenNode[V]) DecodeValue() (V, error) {
+	var zero V
+	if !n.tree.isLeaf(n.offset) {
+		return zero, nil
+	}
+	value, err := n.tree.codec.Unmarshal(n.tree.leafValue(n.offset))
+	if err != nil {
+		return zero, err
+	}
+	return value, nil
+}
+
+// Iterator returns an iterator over the tree in ascending order, the nodes it yields
+// also have a DecodeValue() (V, error) method reporting values the codec fails to decode.
+func (t *FrozenTree[V]) Iterator(options ...int) Iterator[V] {
+	opts := 0
+	for _, opt := range options {
+		opts |= opt
+	}
+	if opts&TraverseAll == 0 {
+		opts = TraverseLeaf
+	}
+
+	it := &frozenIterator[V]{tree: t, options: opts}
+	if t.root != 0 {
+		it.stack = append(it.stack, t.root)
+	}
+	it.advance()
+	return it
+}
+
+func (it *frozenIterator[V]) HasNext() bool {
+	return it.next != nil
+}
+
+func (it *frozenIterator[V]) Next() (Node[V], error) {
+	if !it.HasNext() {
+		return nil, ErrNoMoreNodes
+	}
+	n := it.next
+	it.advance()
+	return n, nil
+}
+
+// advance pops nodes in preorder until one matches the options
+func (it *frozenIterator[V]) advance() {
+	t := it.tree
+	it.next = nil
+	for len(it.stack) > 0 {
+		n := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+
+		if !t.isLeaf(n) {
+			// children are pushed in reverse, so the least is on top
+			top := len(it.stack)
+			if zeroChild := t.zeroChild(n); zeroChild != 0 {
+				it.stack = append(it.stack, zeroChild)
+			}
+			t.forEachChild(n, func(child uint64) bool {
+				it.stack = append(it.stack, child)
+				return true
+			})
+			for i, j := top, len(it.stack)-1; i < j; i, j = i+1, j-1 {
+				it.stack[i], it.stack[j] = it.stack[j], it.stack[i]
+			}
+		}
+
+		if t.isLeaf(n) && it.options&TraverseLeaf != 0 || !t.isLeaf(n) && it.options&TraverseNode != 0 {
+			it.next = t.node(n)
+			return
+		}
+	}
+}
+
+// partitionSorted splits at least two sorted and unique keys sharing their first depth bytes
+// by their byte at end, where end is depth plus the length of the prefix shared by all keys.
+// zero tells whether keys[0] ends at end, it becomes zeroChild. Child i holds keys[groups[i]:groups[i+1]].
+func partitionSorted(keys []Key, depth uint32) (end uint32, zero bool, groups []int) {
+	// keys are sorted, so the common prefix of the first and the last one is shared by all
+	first, last := keys[0], keys[len(keys)-1]
+	end = depth
+	for int(end) < len(first) && int(end) < len(last) && first[end] == last[end] {
+		end++
+	}
+
+	start := 0
+	if !first.valid(int(end)) {
+		zero = true
+		start = 1
+	}
+
+	groups = append(groups, start)
+	for i := start + 1; i < len(keys); i++ {
+		if keys[i][end] != keys[i-1][end] {
+			groups = append(groups, i)
+		}
+	}
+	return end, zero, append(groups, len(keys))
+}
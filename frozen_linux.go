@@ -0,0 +1,41 @@
+//go:build linux
+
+package art
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenFrozen maps a file written by WriteFrozen into memory, nodes are read in place,
+// so the tree opens instantly and pages are shared by every process opening the file.
+func OpenFrozen[V any](path string, codec Codec[V]) (*FrozenTree[V], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < frozenHeaderLen+frozenTrailerLen {
+		return nil, ErrFrozenFormat
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := NewFrozen(data, codec)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+	t.unmap = func() error {
+		return syscall.Munmap(data)
+	}
+	return t, nil
+}
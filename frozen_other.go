@@ -0,0 +1,14 @@
+//go:build !linux
+
+package art
+
+import "os"
+
+// OpenFrozen reads a file written by WriteFrozen, it is mapped into memory on linux only.
+func OpenFrozen[V any](path string, codec Codec[V]) (*FrozenTree[V], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFrozen(data, codec)
+}
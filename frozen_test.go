@@ -0,0 +1,150 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ Reader[int] = New[int]()
+	_ Reader[int] = NewImmutable[int]()
+	_ Reader[int] = &FrozenTree[int]{}
+)
+
+func TestFrozenTree(t *testing.T) {
+	keys := []string{"", "a", "ab", "abc", "api.foo", "api.foo.bar", "api.foe.fum", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix"}
+	for i := 0; i < 256; i++ {
+		keys = append(keys, string([]byte{'z', byte(i)}))
+		if i < 30 {
+			keys = append(keys, fmt.Sprintf("m%c", 'A'+i))
+		}
+		if i < 10 {
+			keys = append(keys, fmt.Sprintf("n%d", i))
+		}
+	}
+	src := New[int]()
+	for i, k := range keys {
+		src.Insert(Key(k), i)
+	}
+
+	path := filepath.Join(t.TempDir(), "frozen")
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	n, err := WriteFrozen[int](f, src, intCodec{})
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	info, _ := os.Stat(path)
+	assert.Equal(t, info.Size(), n)
+
+	tree, err := OpenFrozen[int](path, intCodec{})
+	assert.NoError(t, err)
+	defer tree.Close()
+
+	assert.Equal(t, src.Size(), tree.Size())
+	for i, k := range keys {
+		v, found := tree.Search(Key(k))
+		assert.True(t, found, k)
+		assert.Equal(t, i, v)
+	}
+	for _, k := range []string{"ap", "abcd", "api.foo.ba", "this:key:has:a:long:common:prefiz:1", "z", "m"} {
+		assert.False(t, tree.Contains(Key(k)), k)
+	}
+
+	for _, prefix := range []string{"", "a", "api.f", "api.foo", "this:key:has:a:long:c", "this:key:has:a:long:x", "m", "z\xff", "q"} {
+		assert.Equal(t, src.ForEachKeyPrefix(Key(prefix)), tree.ForEachKeyPrefix(Key(prefix)), prefix)
+	}
+
+	var iterated []string
+	for it := tree.Iterator(); it.HasNext(); {
+		n, err := it.Next()
+		assert.NoError(t, err)
+		v, _ := src.Search(n.Key())
+		assert.Equal(t, v, n.Value())
+		iterated = append(iterated, n.Key().String())
+	}
+	assert.Equal(t, src.ForEachKeyPrefix(nil), iterated)
+
+	// node types are chosen by the number of children
+	types := map[NodeType]int{}
+	for it := tree.Iterator(TraverseNode); it.HasNext(); {
+		n, _ := it.Next()
+		assert.Nil(t, n.Key())
+		types[n.Type()]++
+	}
+	assert.Equal(t, map[NodeType]int{Node4: 6, Node16: 2, Node48: 1, Node256: 1}, types)
+	_, err = tree.Iterator(TraverseNode).Next()
+	assert.NoError(t, err)
+}
+
+func TestFrozenTreeEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := WriteFrozen[string](&buf, NewImmutable[string](), nil)
+	assert.NoError(t, err)
+
+	tree, err := NewFrozen[string](buf.Bytes(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tree.Size())
+	assert.False(t, tree.Contains(nil))
+	assert.Empty(t, tree.ForEachKeyPrefix(nil))
+	assert.False(t, tree.Iterator().HasNext())
+
+	for _, data := range [][]byte{nil, []byte("ARTF"), buf.Bytes()[:buf.Len()-1]} {
+		_, err = NewFrozen[string](data, nil)
+		assert.ErrorIs(t, err, ErrFrozenFormat)
+	}
+}
+
+// corruptCodec writes values which intCodec cannot decode for negative numbers
+type corruptCodec struct {
+	intCodec
+}
+
+func (corruptCodec) Marshal(v int) ([]byte, error) {
+	if v < 0 {
+		return []byte("corrupt"), nil
+	}
+	return intCodec{}.Marshal(v)
+}
+
+func TestFrozenTreeCorruptValue(t *testing.T) {
+	src := NewImmutable[int]()
+	src, _, _ = src.Insert(Key("bad"), -1)
+	src, _, _ = src.Insert(Key("good"), 0)
+
+	var buf bytes.Buffer
+	_, err := WriteFrozen[int](&buf, src, corruptCodec{})
+	assert.NoError(t, err)
+	tree, err := NewFrozen[int](buf.Bytes(), intCodec{})
+	assert.NoError(t, err)
+
+	v, found, err := tree.Get(Key("good"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0, v)
+
+	// the key of a corrupt value is found by Search and Contains alike, Get reports the error
+	_, found, err = tree.Get(Key("bad"))
+	assert.True(t, found)
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+	v, found = tree.Search(Key("bad"))
+	assert.True(t, found)
+	assert.Equal(t, 0, v)
+	assert.True(t, tree.Contains(Key("bad")))
+	_, found, err = tree.Get(Key("missing"))
+	assert.False(t, found)
+	assert.NoError(t, err)
+
+	it := tree.Iterator()
+	n, err := it.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Key("bad"), n.Key())
+	assert.Equal(t, 0, n.Value())
+	_, err = n.(interface{ DecodeValue() (int, error) }).DecodeValue()
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+}
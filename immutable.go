@@ -0,0 +1,118 @@
+package art
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+func NewImmutable[V any]() *ImmutableTree[V] {
+	return &ImmutableTree[V]{tree: &tree[V]{}}
+}
+
+// Insert returns a new tree with value stored under key,
+// only the nodes on the path from root to the new leaf are copied.
+func (t *ImmutableTree[V]) Insert(key Key, value V) (*ImmutableTree[V], V, bool) {
+	w := t.tree.copyOnWrite()
+	oldValue, updated := w.Insert(key, value)
+	return w.freeze(), oldValue, updated
+}
+
+// Delete returns a new tree without key, t is returned if key does not exist.
+func (t *ImmutableTree[V]) Delete(key Key) (*ImmutableTree[V], V, bool) {
+	if !t.tree.Contains(key) {
+		var zero V
+		return t, zero, false
+	}
+
+	w := t.tree.copyOnWrite()
+	value, deleted := w.Delete(key)
+	return w.freeze(), value, deleted
+}
+
+func (t *ImmutableTree[V]) Search(key Key) (V, bool) {
+	return t.tree.Search(key)
+}
+
+func (t *ImmutableTree[V]) Contains(key Key) bool {
+	return t.tree.Contains(key)
+}
+
+func (t *ImmutableTree[V]) Floor(key Key) (Key, V, bool) {
+	return t.tree.Floor(key)
+}
+
+func (t *ImmutableTree[V]) Ceiling(key Key) (Key, V, bool) {
+	return t.tree.Ceiling(key)
+}
+
+func (t *ImmutableTree[V]) LongestPrefix(key Key) (Key, V, bool) {
+	return t.tree.LongestPrefix(key)
+}
+
+func (t *ImmutableTree[V]) ForEachKeyPrefix(prefix Key) []string {
+	return t.tree.ForEachKeyPrefix(prefix)
+}
+
+func (t *ImmutableTree[V]) Range(start, end Key, callback Callback[V], options ...int) {
+	t.tree.Range(start, end, callback, options...)
+}
+
+func (t *ImmutableTree[V]) Descend(callback Callback[V]) {
+	t.tree.Descend(callback)
+}
+
+func (t *ImmutableTree[V]) Iterator(options ...int) Iterator[V] {
+	return t.tree.Iterator(options...)
+}
+
+func (t *ImmutableTree[V]) ReverseIterator() Iterator[V] {
+	return t.tree.ReverseIterator()
+}
+
+func (t *ImmutableTree[V]) Cursor() *Cursor[V] {
+	return t.tree.Cursor()
+}
+
+func (t *ImmutableTree[V]) Size() int {
+	return t.tree.Size()
+}
+
+func (t *ImmutableTree[V]) Stats() Stats {
+	return t.tree.Stats()
+}
+
+func (t *ImmutableTree[V]) Validate() error {
+	return t.tree.Validate()
+}
+
+func (t *ImmutableTree[V]) Dump(w io.Writer, format DumpFormat) error {
+	return t.tree.Dump(w, format)
+}
+
+func (t *ImmutableTree[V]) rootNode() *artNode[V] {
+	return t.tree.root
+}
+
+// copyOnWrite returns a tree which shares all nodes with t,
+// nodes are copied before being modified.
+func (t *tree[V]) copyOnWrite() *tree[V] {
+	return &tree[V]{
+		size:  t.size,
+		root:  t.root,
+		owner: newOwner(),
+	}
+}
+
+// owners counts the owners given to copy-on-write trees, zero is never given
+var owners atomic.Uint64
+
+// newOwner returns an owner which no node is tagged with yet
+func newOwner() uint64 {
+	return owners.Add(1)
+}
+
+// freeze turns a copy-on-write tree into an immutable tree
+func (t *tree[V]) freeze() *ImmutableTree[V] {
+	t.owner = 0
+	return &ImmutableTree[V]{tree: t}
+}
@@ -0,0 +1,99 @@
+package art
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImmutableTree(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3"}
+	for i := 0; i < node256Max; i++ {
+		keys = append(keys, string([]byte{'z', byte(i)}))
+	}
+
+	versions := []*ImmutableTree[int]{NewImmutable[int]()}
+	for i, k := range keys {
+		next, _, updated := versions[len(versions)-1].Insert(Key(k), i)
+		assert.False(t, updated)
+		versions = append(versions, next)
+	}
+
+	// delete keys in a different order, shrinking and collapsing nodes
+	for i := len(keys) - 1; i >= 0; i -= 2 {
+		next, v, deleted := versions[len(versions)-1].Delete(Key(keys[i]))
+		assert.True(t, deleted)
+		assert.Equal(t, i, v)
+		versions = append(versions, next)
+	}
+
+	last := versions[len(versions)-1]
+	same, _, deleted := last.Delete(Key("missing"))
+	assert.False(t, deleted)
+	assert.Equal(t, last, same)
+
+	updated, old, found := last.Insert(Key("a"), -1)
+	assert.True(t, found)
+	assert.Equal(t, 1, old)
+
+	v, _ := updated.Search(Key("a"))
+	assert.Equal(t, -1, v)
+	v, _ = last.Search(Key("a"))
+	assert.Equal(t, 1, v)
+
+	// every version still holds exactly the keys it was built with
+	expected := map[string]int{}
+	check := func(version *ImmutableTree[int]) {
+		assert.Equal(t, len(expected), version.Size())
+		expectedKeys := make([]string, 0, len(expected))
+		for k, i := range expected {
+			v, found := version.Search(Key(k))
+			assert.True(t, found, k)
+			assert.Equal(t, i, v, k)
+			expectedKeys = append(expectedKeys, k)
+		}
+		sort.Strings(expectedKeys)
+
+		actual := make([]string, 0)
+		for it := version.Iterator(); it.HasNext(); {
+			n, _ := it.Next()
+			actual = append(actual, n.Key().String())
+		}
+		assert.Equal(t, expectedKeys, actual)
+		assertKeyCounts(t, version.tree.root)
+	}
+
+	check(versions[0])
+	for i, k := range keys {
+		expected[k] = i
+		check(versions[i+1])
+	}
+	idx := len(keys) + 1
+	for i := len(keys) - 1; i >= 0; i -= 2 {
+		delete(expected, keys[i])
+		check(versions[idx])
+		idx++
+	}
+}
+
+func TestImmutableTreeSharing(t *testing.T) {
+	tree := NewImmutable[string]()
+	for _, k := range []string{"a1", "a2", "b1", "b2", "c1"} {
+		tree, _, _ = tree.Insert(Key(k), k)
+	}
+
+	next, _, _ := tree.Insert(Key("a3"), "a3")
+	assert.NotEqual(t, tree.tree.root, next.tree.root)
+
+	child := func(tree *ImmutableTree[string], c byte) *artNode[string] {
+		return tree.tree.root.child(c, true)
+	}
+	// only the path to the new leaf is copied
+	assert.NotEqual(t, child(tree, 'a'), child(next, 'a'))
+	assert.Equal(t, child(tree, 'b'), child(next, 'b'))
+	assert.Equal(t, child(tree, 'c'), child(next, 'c'))
+
+	assert.Equal(t, []string{"a1", "a2"}, tree.ForEachKeyPrefix(Key("a")))
+	assert.Equal(t, []string{"a1", "a2", "a3"}, next.ForEachKeyPrefix(Key("a")))
+}
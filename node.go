@@ -3,9 +3,10 @@ package art
 import (
 	"bytes"
 	"math/bits"
+	"unsafe"
 )
 
-func (l *leaf) prefixMatch(key Key) bool {
+func (l *leaf[V]) prefixMatch(key Key) bool {
 	if len(l.key) < len(key) {
 		return false
 	}
@@ -13,25 +14,41 @@ func (l *leaf) prefixMatch(key Key) bool {
 	return bytes.Compare(l.key[:len(key)], key) == 0
 }
 
-func (l *leaf) match(key Key) bool {
+func (l *leaf[V]) match(key Key) bool {
 	if len(l.key) != len(key) {
 		return false
 	}
 	return bytes.Compare(l.key, key) == 0
 }
 
-func (an *artNode) Type() NodeType {
+func (an *artNode[V]) Type() NodeType {
 	return an._type
 }
 
-func (an *artNode) Key() Key {
+func (an *artNode[V]) Key() Key {
 	if an.isLeaf() {
 		return an.leaf().key
 	}
 	return nil
 }
 
-func (an *artNode) match(key Key, depth uint32) uint32 {
+func (an *artNode[V]) Value() V {
+	if an.isLeaf() {
+		return an.leaf().value
+	}
+	var zero V
+	return zero
+}
+
+// keyCount returns the number of keys under a artNode
+func (an *artNode[V]) keyCount() int {
+	if an.isLeaf() {
+		return 1
+	}
+	return an.node().numKeys
+}
+
+func (an *artNode[V]) match(key Key, depth uint32) uint32 {
 	idx := uint32(0)
 	if len(key)-int(depth) < 0 {
 		return idx
@@ -49,7 +66,7 @@ func (an *artNode) match(key Key, depth uint32) uint32 {
 }
 
 // find the minium leaf under a artNode
-func (an *artNode) minimum() *leaf {
+func (an *artNode[V]) minimum() *leaf[V] {
 
 	switch an._type {
 	case Leaf:
@@ -96,8 +113,22 @@ func (an *artNode) minimum() *leaf {
 	return nil
 }
 
+// find the maximum leaf under a artNode
+func (an *artNode[V]) maximum() *leaf[V] {
+	if an.isLeaf() {
+		return an.leaf()
+	}
+	if _, child := an.childBefore(node256Max); child != nil {
+		return child.maximum()
+	}
+	if zeroChild := an.node().zeroChild; zeroChild != nil {
+		return zeroChild.maximum()
+	}
+	return nil
+}
+
 // find mismatch index between key and leaf
-func (an *artNode) matchDeep(key Key, depth uint32) uint32 {
+func (an *artNode[V]) matchDeep(key Key, depth uint32) uint32 {
 	mismatchIdx := an.match(key, depth)
 	if mismatchIdx < MaxPrefixLen {
 		return mismatchIdx
@@ -113,9 +144,8 @@ func (an *artNode) matchDeep(key Key, depth uint32) uint32 {
 	return mismatchIdx
 }
 
-var nodeNotFound *artNode
-
-func (an *artNode) findChild(c byte, valid bool) **artNode {
+// findChild returns the reference to the child under c, nil if there is none
+func (an *artNode[V]) findChild(c byte, valid bool) **artNode[V] {
 	node := an.node()
 
 	if !valid {
@@ -136,10 +166,18 @@ func (an *artNode) findChild(c byte, valid bool) **artNode {
 		}
 	}
 
-	return &nodeNotFound
+	return nil
+}
+
+// child returns the child under c, nil if there is none
+func (an *artNode[V]) child(c byte, valid bool) *artNode[V] {
+	if ref := an.findChild(c, valid); ref != nil {
+		return *ref
+	}
+	return nil
 }
 
-func (an *artNode) index(c byte) int {
+func (an *artNode[V]) index(c byte) int {
 	switch an._type {
 	case Node4:
 		node := an.node4()
@@ -176,7 +214,155 @@ func (an *artNode) index(c byte) int {
 	return -1
 }
 
-func (an *artNode) addChild(c byte, valid bool, child *artNode) bool {
+// forEachChild calls f with every child except zeroChild in ascending key order until f returns false
+func (an *artNode[V]) forEachChild(f func(c byte, child *artNode[V]) bool) bool {
+	switch an._type {
+	case Node4:
+		node := an.node4()
+		for i := 0; i < int(node.numChildren); i++ {
+			if !f(node.keys[i], node.children[i]) {
+				return false
+			}
+		}
+	case Node16:
+		node := an.node16()
+		for i := 0; i < int(node.numChildren); i++ {
+			if !f(node.keys[i], node.children[i]) {
+				return false
+			}
+		}
+	case Node48:
+		node := an.node48()
+		for i := 0; i < node256Max; i++ {
+			if node.present[i>>n48s]&(1<<(i%n48m)) == 0 {
+				continue
+			}
+			if !f(byte(i), node.children[node.keys[i]]) {
+				return false
+			}
+		}
+	case Node256:
+		node := an.node256()
+		for i, child := range node.children {
+			if child == nil {
+				continue
+			}
+			if !f(byte(i), child) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// childAfter returns the position and child of the least child after pos,
+// positions are indexes of children in node4 & node16 and key bytes in node48 & node256,
+// childAfter(nullIdx) returns the least child.
+func (an *artNode[V]) childAfter(pos int) (int, *artNode[V]) {
+	switch an._type {
+	case Node4:
+		node := an.node4()
+		if pos+1 < int(node.numChildren) {
+			return pos + 1, node.children[pos+1]
+		}
+	case Node16:
+		node := an.node16()
+		if pos+1 < int(node.numChildren) {
+			return pos + 1, node.children[pos+1]
+		}
+	case Node48:
+		node := an.node48()
+		for i := pos + 1; i < node256Max; i++ {
+			if node.present[i>>n48s]&(1<<(i%n48m)) != 0 {
+				return i, node.children[node.keys[i]]
+			}
+		}
+	case Node256:
+		node := an.node256()
+		for i := pos + 1; i < node256Max; i++ {
+			if node.children[i] != nil {
+				return i, node.children[i]
+			}
+		}
+	}
+	return nullIdx, nil
+}
+
+// childNotLess returns the position and child of the least child whose key is not less than c
+func (an *artNode[V]) childNotLess(c byte) (int, *artNode[V]) {
+	switch an._type {
+	case Node4, Node16:
+		for pos, child := an.childAfter(nullIdx); child != nil; pos, child = an.childAfter(pos) {
+			if an.keyAt(pos) >= c {
+				return pos, child
+			}
+		}
+		return nullIdx, nil
+	}
+	return an.childAfter(int(c) - 1)
+}
+
+// keyAt returns key byte of the child at pos
+func (an *artNode[V]) keyAt(pos int) byte {
+	switch an._type {
+	case Node4:
+		return an.node4().keys[pos]
+	case Node16:
+		return an.node16().keys[pos]
+	}
+	return byte(pos)
+}
+
+// childBefore returns the position and child of the greatest child before pos,
+// positions are indexes of children in node4 & node16 and key bytes in node48 & node256,
+// childBefore(node256Max) returns the greatest child.
+func (an *artNode[V]) childBefore(pos int) (int, *artNode[V]) {
+	switch an._type {
+	case Node4:
+		node := an.node4()
+		if pos > int(node.numChildren) {
+			pos = int(node.numChildren)
+		}
+		if pos > 0 {
+			return pos - 1, node.children[pos-1]
+		}
+	case Node16:
+		node := an.node16()
+		if pos > int(node.numChildren) {
+			pos = int(node.numChildren)
+		}
+		if pos > 0 {
+			return pos - 1, node.children[pos-1]
+		}
+	case Node48:
+		node := an.node48()
+		for i := pos - 1; i >= 0; i-- {
+			if node.present[i>>n48s]&(1<<(i%n48m)) != 0 {
+				return i, node.children[node.keys[i]]
+			}
+		}
+	case Node256:
+		node := an.node256()
+		for i := pos - 1; i >= 0; i-- {
+			if node.children[i] != nil {
+				return i, node.children[i]
+			}
+		}
+	}
+	return nullIdx, nil
+}
+
+// prefixBytes returns the whole compressed prefix of a node at depth,
+// bytes beyond MaxPrefixLen are read from the minimum leaf
+func (an *artNode[V]) prefixBytes(depth uint32) []byte {
+	node := an.node()
+	if node.prefixLen <= MaxPrefixLen {
+		return node.prefix[:node.prefixLen]
+	}
+	return an.minimum().key[depth : depth+node.prefixLen]
+}
+
+func (an *artNode[V]) addChild(c byte, valid bool, child *artNode[V]) bool {
 	switch an._type {
 	case Node4:
 		return an._addChild4(c, valid, child)
@@ -190,9 +376,15 @@ func (an *artNode) addChild(c byte, valid bool, child *artNode) bool {
 	return false
 }
 
-func (an *artNode) _addChild4(c byte, valid bool, child *artNode) bool {
+func (an *artNode[V]) _addChild4(c byte, valid bool, child *artNode[V]) bool {
 	node := an.node4()
 
+	// zero byte in the key, zeroChild does not take a slot
+	if !valid {
+		node.zeroChild = child
+		return false
+	}
+
 	// grow to node16
 	if node.numChildren >= node4Max {
 		newNode := an.grow()
@@ -201,12 +393,6 @@ func (an *artNode) _addChild4(c byte, valid bool, child *artNode) bool {
 		return true
 	}
 
-	// zero byte in the key
-	if !valid {
-		node.zeroChild = child
-		return false
-	}
-
 	i := uint16(0)
 	// maintain sorted order
 	for ; i < node.numChildren; i++ {
@@ -231,9 +417,15 @@ func (an *artNode) _addChild4(c byte, valid bool, child *artNode) bool {
 	return true
 }
 
-func (an *artNode) _addChild16(c byte, valid bool, child *artNode) bool {
+func (an *artNode[V]) _addChild16(c byte, valid bool, child *artNode[V]) bool {
 	node := an.node16()
 
+	// zeroChild does not take a slot
+	if !valid {
+		node.zeroChild = child
+		return false
+	}
+
 	if node.numChildren >= node16Max {
 		newNode := an.grow()
 		newNode.addChild(c, valid, child)
@@ -241,17 +433,12 @@ func (an *artNode) _addChild16(c byte, valid bool, child *artNode) bool {
 		return true
 	}
 
-	if !valid {
-		node.zeroChild = child
-		return false
-	}
-
 	idx := node.numChildren
 	bitfield := uint(0)
 	for i := uint(0); i < node16Max; i++ {
 		if node.keys[i] > c {
 			// mark index of key which is greater than c
-			bitfield |= (i << i)
+			bitfield |= (1 << i)
 		}
 	}
 
@@ -279,20 +466,21 @@ func (an *artNode) _addChild16(c byte, valid bool, child *artNode) bool {
 	node.numChildren++
 	return true
 }
-func (an *artNode) _addChild48(c byte, valid bool, child *artNode) bool {
+func (an *artNode[V]) _addChild48(c byte, valid bool, child *artNode[V]) bool {
 	node := an.node48()
 
-	if node.numChildren >= node16Max {
+	// zeroChild does not take a slot
+	if !valid {
+		node.zeroChild = child
+		return false
+	}
+
+	if node.numChildren >= node48Max {
 		newNode := an.grow()
 		newNode.addChild(c, valid, child)
 		replaceNode(an, newNode)
 		return true
 	}
-
-	if !valid {
-		node.zeroChild = child
-		return false
-	}
 	index := byte(0)
 	for node.children[index] != nil {
 		index++
@@ -305,7 +493,7 @@ func (an *artNode) _addChild48(c byte, valid bool, child *artNode) bool {
 
 	return true
 }
-func (an *artNode) _addChild256(c byte, valid bool, child *artNode) bool {
+func (an *artNode[V]) _addChild256(c byte, valid bool, child *artNode[V]) bool {
 	node := an.node256()
 
 	if !valid {
@@ -318,11 +506,11 @@ func (an *artNode) _addChild256(c byte, valid bool, child *artNode) bool {
 	return true
 }
 
-func (an *artNode) grow() *artNode {
+func (an *artNode[V]) grow() *artNode[V] {
 	switch an._type {
 	case Node4:
 		// copy old node meta
-		node := newNode16().copyMeta(an)
+		node := newNode16[V]().copyMeta(an)
 
 		d := node.node16()
 		s := an.node4()
@@ -338,7 +526,7 @@ func (an *artNode) grow() *artNode {
 		}
 		return node
 	case Node16:
-		node := newNode48().copyMeta(an)
+		node := newNode48[V]().copyMeta(an)
 
 		d := node.node48()
 		s := an.node16()
@@ -360,7 +548,7 @@ func (an *artNode) grow() *artNode {
 		}
 		return node
 	case Node48:
-		node := newNode256().copyMeta(an)
+		node := newNode256[V]().copyMeta(an)
 		d := node.node256()
 		s := an.node48()
 		d.zeroChild = s.zeroChild
@@ -375,7 +563,248 @@ func (an *artNode) grow() *artNode {
 	return nil
 }
 
-func (an *artNode) copyMeta(src *artNode) *artNode {
+func (an *artNode[V]) removeChild(c byte, valid bool) {
+	switch an._type {
+	case Node4:
+		an._removeChild4(c, valid)
+	case Node16:
+		an._removeChild16(c, valid)
+	case Node48:
+		an._removeChild48(c, valid)
+	case Node256:
+		an._removeChild256(c, valid)
+	}
+}
+
+func (an *artNode[V]) _removeChild4(c byte, valid bool) {
+	node := an.node4()
+
+	if !valid {
+		node.zeroChild = nil
+	} else if idx := an.index(c); idx != -1 {
+		// shift left & remove key
+		for i := idx; i < int(node.numChildren)-1; i++ {
+			node.keys[i] = node.keys[i+1]
+			node.present[i] = node.present[i+1]
+			node.children[i] = node.children[i+1]
+		}
+		node.numChildren--
+		node.keys[node.numChildren] = 0
+		node.present[node.numChildren] = 0
+		node.children[node.numChildren] = nil
+	}
+
+	numChildren := node.numChildren
+	if node.zeroChild != nil {
+		numChildren++
+	}
+	if numChildren < node4Min {
+		an.collapse()
+	}
+}
+
+func (an *artNode[V]) _removeChild16(c byte, valid bool) {
+	node := an.node16()
+
+	if !valid {
+		node.zeroChild = nil
+		return
+	}
+
+	idx := an.index(c)
+	if idx == -1 {
+		return
+	}
+	for i := idx; i < int(node.numChildren)-1; i++ {
+		node.keys[i] = node.keys[i+1]
+		node.children[i] = node.children[i+1]
+	}
+	node.numChildren--
+	node.keys[node.numChildren] = 0
+	node.children[node.numChildren] = nil
+	// children are always packed to the left, so are the present bits
+	node.present = (1 << node.numChildren) - 1
+
+	if node.numChildren < node16Min {
+		replaceNode(an, an.shrink())
+	}
+}
+
+func (an *artNode[V]) _removeChild48(c byte, valid bool) {
+	node := an.node48()
+
+	if !valid {
+		node.zeroChild = nil
+		return
+	}
+
+	if node.present[c>>n48s]&(1<<(c%n48m)) == 0 {
+		return
+	}
+	node.children[node.keys[c]] = nil
+	node.keys[c] = 0
+	node.present[c>>n48s] &^= 1 << (c % n48m)
+	node.numChildren--
+
+	if node.numChildren < node48Min {
+		replaceNode(an, an.shrink())
+	}
+}
+
+func (an *artNode[V]) _removeChild256(c byte, valid bool) {
+	node := an.node256()
+
+	if !valid {
+		node.zeroChild = nil
+		return
+	}
+
+	if node.children[c] == nil {
+		return
+	}
+	node.children[c] = nil
+	node.numChildren--
+
+	if node.numChildren < node256Min {
+		replaceNode(an, an.shrink())
+	}
+}
+
+// shrink is the reverse of grow
+func (an *artNode[V]) shrink() *artNode[V] {
+	switch an._type {
+	case Node16:
+		node := newNode4[V]().copyMeta(an)
+
+		d := node.node4()
+		s := an.node16()
+		d.zeroChild = s.zeroChild
+
+		for i := 0; i < int(s.numChildren); i++ {
+			d.keys[i] = s.keys[i]
+			d.present[i] = 1
+			d.children[i] = s.children[i]
+		}
+		return node
+	case Node48:
+		node := newNode16[V]().copyMeta(an)
+
+		d := node.node16()
+		s := an.node48()
+		d.zeroChild = s.zeroChild
+
+		numChildren := 0
+		for i := 0; i < node256Max; i++ {
+			if s.present[i>>n48s]&(1<<(i%n48m)) != 0 {
+				// bytes are visited in order, keys of node16 stay sorted
+				d.keys[numChildren] = byte(i)
+				d.present |= 1 << numChildren
+				d.children[numChildren] = s.children[s.keys[i]]
+				numChildren++
+			}
+		}
+		return node
+	case Node256:
+		node := newNode48[V]().copyMeta(an)
+
+		d := node.node48()
+		s := an.node256()
+		d.zeroChild = s.zeroChild
+
+		var numChildren byte
+		for i := 0; i < node256Max; i++ {
+			if s.children[i] != nil {
+				d.keys[i] = numChildren
+				d.present[i>>n48s] |= 1 << (i % n48m)
+				d.children[numChildren] = s.children[i]
+				numChildren++
+			}
+		}
+		return node
+	}
+	return nil
+}
+
+// collapse replaces a node4 with a single child by the child,
+// the prefix of node4 and the child key are merged into the prefix of child
+func (an *artNode[V]) collapse() {
+	node := an.node4()
+
+	child := node.zeroChild
+	if child == nil {
+		child = node.children[0]
+		if child == nil {
+			return
+		}
+	}
+
+	if !child.isLeaf() {
+		// child may be shared by a copy-on-write tree
+		child = child.clone()
+		cn := child.node()
+
+		var p prefix
+		n := copy(p[:], node.prefix[:min(node.prefixLen, MaxPrefixLen)])
+		if n < MaxPrefixLen {
+			p[n] = node.keys[0]
+			n++
+		}
+		copy(p[n:], cn.prefix[:min(cn.prefixLen, MaxPrefixLen)])
+
+		cn.prefix = p
+		cn.prefixLen += node.prefixLen + 1
+	}
+
+	replaceNode(an, child)
+}
+
+// clone returns a shallow copy of a artNode, children are shared with the origin.
+// The lock word of ConcurrentTree is not read, the copy is unlocked.
+func (an *artNode[V]) clone() *artNode[V] {
+	n := &artNode[V]{_type: an._type}
+	switch an._type {
+	case Leaf:
+		l := *an.leaf()
+		n.ref = unsafe.Pointer(&l)
+		return n
+	case Node4:
+		src := an.node4()
+		n.ref = unsafe.Pointer(&node4[V]{children: src.children, keys: src.keys, present: src.present})
+	case Node16:
+		src := an.node16()
+		n.ref = unsafe.Pointer(&node16[V]{children: src.children, keys: src.keys, present: src.present})
+	case Node48:
+		src := an.node48()
+		n.ref = unsafe.Pointer(&node48[V]{children: src.children, keys: src.keys, present: src.present})
+	case Node256:
+		src := an.node256()
+		n.ref = unsafe.Pointer(&node256[V]{children: src.children})
+	}
+
+	src, dst := an.node(), n.node()
+	dst.prefixLen = src.prefixLen
+	dst.prefix = src.prefix
+	dst.numChildren = src.numChildren
+	dst.numKeys = src.numKeys
+	dst.zeroChild = src.zeroChild
+	return n
+}
+
+// owned reports whether an inner node is tagged with owner, leaves are never owned
+// and are copied on every write by a copy-on-write tree
+func (an *artNode[V]) owned(owner uint64) bool {
+	return !an.isLeaf() && an.node().owner == owner
+}
+
+// setOwner tags an inner node with owner, zero tags nothing
+func (an *artNode[V]) setOwner(owner uint64) *artNode[V] {
+	if owner != 0 && !an.isLeaf() {
+		an.node().owner = owner
+	}
+	return an
+}
+
+func (an *artNode[V]) copyMeta(src *artNode[V]) *artNode[V] {
 	if src == nil {
 		return an
 	}
@@ -384,6 +813,7 @@ func (an *artNode) copyMeta(src *artNode) *artNode {
 
 	d.prefixLen = s.prefixLen
 	d.numChildren = s.numChildren
+	d.numKeys = s.numKeys
 
 	for i, limit := 0, min(s.prefixLen, MaxPrefixLen); i < int(limit); i++ {
 		d.prefix[i] = s.prefix[i]
@@ -392,30 +822,30 @@ func (an *artNode) copyMeta(src *artNode) *artNode {
 	return an
 }
 
-func (an *artNode) node() *node {
-	return (*node)(an.ref)
+func (an *artNode[V]) node() *node[V] {
+	return (*node[V])(an.ref)
 }
 
-func (an *artNode) node4() *node4 {
-	return (*node4)(an.ref)
+func (an *artNode[V]) node4() *node4[V] {
+	return (*node4[V])(an.ref)
 }
 
-func (an *artNode) node16() *node16 {
-	return (*node16)(an.ref)
+func (an *artNode[V]) node16() *node16[V] {
+	return (*node16[V])(an.ref)
 }
 
-func (an *artNode) node48() *node48 {
-	return (*node48)(an.ref)
+func (an *artNode[V]) node48() *node48[V] {
+	return (*node48[V])(an.ref)
 }
 
-func (an *artNode) node256() *node256 {
-	return (*node256)(an.ref)
+func (an *artNode[V]) node256() *node256[V] {
+	return (*node256[V])(an.ref)
 }
 
-func (an *artNode) leaf() *leaf {
-	return (*leaf)(an.ref)
+func (an *artNode[V]) leaf() *leaf[V] {
+	return (*leaf[V])(an.ref)
 }
-func (an *artNode) setPrefix(key Key, prefixLen uint32) *artNode {
+func (an *artNode[V]) setPrefix(key Key, prefixLen uint32) *artNode[V] {
 	nh := an.node()
 	nh.prefixLen = prefixLen
 	for i := uint32(0); i < min(prefixLen, MaxPrefixLen); i++ {
@@ -424,11 +854,11 @@ func (an *artNode) setPrefix(key Key, prefixLen uint32) *artNode {
 	return an
 }
 
-func (an *artNode) isLeaf() bool {
+func (an *artNode[V]) isLeaf() bool {
 	return an._type == Leaf
 }
 
-func longestCommonPrefix(l1, l2 *leaf, depth uint32) uint32 {
+func longestCommonPrefix[V any](l1, l2 *leaf[V], depth uint32) uint32 {
 	idx, limit := depth, min(uint32(len(l1.key)), uint32(len(l2.key)))
 	for ; idx < limit; idx++ {
 		if l1.key[idx] != l2.key[idx] {
@@ -446,10 +876,10 @@ func min(a, b uint32) uint32 {
 }
 
 // modify oldNode ptr, ** means ref to pointer
-func replaceRef(oldNode **artNode, newNode *artNode) {
+func replaceRef[V any](oldNode **artNode[V], newNode *artNode[V]) {
 	*oldNode = newNode
 }
 
-func replaceNode(oldNode *artNode, newNode *artNode) {
+func replaceNode[V any](oldNode *artNode[V], newNode *artNode[V]) {
 	*oldNode = *newNode
 }
@@ -0,0 +1,380 @@
+package art
+
+import "bytes"
+
+// Union returns a tree with the keys of a and b, merge returns the value of a key in both,
+// a nil merge takes the value of b.
+func Union[V any](a, b Reader[V], merge func(key Key, a, b V) V) Tree[V] {
+	return combine(setUnion, a, b, merge)
+}
+
+// Intersect returns a tree with the keys in both a and b, merge returns the value of a key,
+// a nil merge takes the value of b.
+func Intersect[V any](a, b Reader[V], merge func(key Key, a, b V) V) Tree[V] {
+	return combine(setIntersect, a, b, merge)
+}
+
+// Difference returns a tree with the keys of a which are not in b.
+func Difference[V any](a, b Reader[V]) Tree[V] {
+	return combine(setDifference, a, b, nil)
+}
+
+// combine walks both trees in lockstep, a subtree on one side only is adopted or dropped as a whole.
+// If a and b are both immutable the result shares adopted subtrees with them and copies a node
+// before modifying it, otherwise adopted subtrees are copied and the result shares no node with a or b.
+func combine[V any](op setOp, a, b Reader[V], merge func(key Key, a, b V) V) Tree[V] {
+	if merge == nil {
+		merge = func(_ Key, _, b V) V {
+			return b
+		}
+	}
+	m := &setMerger[V]{op: op, merge: merge}
+	_, immutableA := a.(*ImmutableTree[V])
+	_, immutableB := b.(*ImmutableTree[V])
+	if immutableA && immutableB {
+		m.owner = newOwner()
+	}
+
+	ra, okA := a.(rooted[V])
+	rb, okB := b.(rooted[V])
+	if !okA || !okB {
+		return m.combineSorted(a, b)
+	}
+
+	root := m.combine(setView[V]{n: ra.rootNode()}, setView[V]{n: rb.rootNode()}, 0)
+	t := &tree[V]{root: root, owner: m.owner}
+	if root != nil {
+		t.size = root.keyCount()
+	}
+	return t
+}
+
+// combine returns the subtree for x and y whose prefixes start at depth
+func (m *setMerger[V]) combine(x, y setView[V], depth uint32) *artNode[V] {
+	switch {
+	case x.n == nil && y.n == nil:
+		return nil
+	case x.n == nil:
+		if m.op == setUnion {
+			return m.adopt(y, depth)
+		}
+		return nil
+	case y.n == nil:
+		if m.op == setIntersect {
+			return nil
+		}
+		return m.adopt(x, depth)
+	case x.n.isLeaf() || y.n.isLeaf():
+		return m.combineLeaf(x, y, depth)
+	}
+
+	rx, ry := x.rest(depth), y.rest(depth)
+	l := 0
+	for l < len(rx) && l < len(ry) && rx[l] == ry[l] {
+		l++
+	}
+	end := depth + uint32(l)
+
+	xZero, xChildren := x.branch(rx, l)
+	yZero, yChildren := y.branch(ry, l)
+
+	zero := m.combine(xZero, yZero, end+1)
+	var children []setChild[V]
+	add := func(c byte, x, y setView[V]) {
+		if n := m.combine(x, y, end+1); n != nil {
+			children = append(children, setChild[V]{c, setView[V]{n: n}})
+		}
+	}
+	i, j := 0, 0
+	for i < len(xChildren) || j < len(yChildren) {
+		switch {
+		case j == len(yChildren) || i < len(xChildren) && xChildren[i].c < yChildren[j].c:
+			add(xChildren[i].c, xChildren[i].v, setView[V]{})
+			i++
+		case i == len(xChildren) || yChildren[j].c < xChildren[i].c:
+			add(yChildren[j].c, setView[V]{}, yChildren[j].v)
+			j++
+		default:
+			add(xChildren[i].c, xChildren[i].v, yChildren[j].v)
+			i++
+			j++
+		}
+	}
+
+	return m.newSetNode(rx[:l], zero, children)
+}
+
+// combineLeaf combines views of which one at least is a leaf
+func (m *setMerger[V]) combineLeaf(x, y setView[V], depth uint32) *artNode[V] {
+	if x.n.isLeaf() && y.n.isLeaf() {
+		lx, ly := x.n.leaf(), y.n.leaf()
+		switch {
+		case lx.match(ly.key):
+			if m.op == setDifference {
+				return nil
+			}
+			return m.own(newLeaf(lx.key, m.merge(lx.key, lx.value, ly.value)))
+		case m.op == setIntersect:
+			return nil
+		case m.op == setDifference:
+			return m.adopt(x, depth)
+		}
+
+		keys, values := []Key{lx.key, ly.key}, []V{lx.value, ly.value}
+		if bytes.Compare(lx.key, ly.key) > 0 {
+			keys[0], keys[1], values[0], values[1] = keys[1], keys[0], values[1], values[0]
+		}
+		return m.ownDeep(buildSorted([]Key{append(Key(nil), keys[0]...), append(Key(nil), keys[1]...)}, values, depth))
+	}
+
+	// a single key against a subtree, the scratch tree copies shared nodes before modifying them
+	scratch := &tree[V]{owner: m.owner}
+	if x.n.isLeaf() {
+		lx := x.n.leaf()
+		switch m.op {
+		case setIntersect:
+			if ly := y.search(lx.key, depth); ly != nil {
+				return m.own(newLeaf(lx.key, m.merge(lx.key, lx.value, ly.value)))
+			}
+			return nil
+		case setDifference:
+			if y.search(lx.key, depth) != nil {
+				return nil
+			}
+			return m.adopt(x, depth)
+		}
+
+		n := m.adopt(y, depth)
+		if ly := searchNode(n, lx.key, depth); ly != nil {
+			scratch.recursiveInsert(&n, lx.key, m.merge(lx.key, lx.value, ly.value), depth)
+		} else {
+			scratch.recursiveInsert(&n, lx.key, lx.value, depth)
+		}
+		return n
+	}
+
+	ly := y.n.leaf()
+	if m.op == setIntersect {
+		if lx := x.search(ly.key, depth); lx != nil {
+			return m.own(newLeaf(ly.key, m.merge(ly.key, lx.value, ly.value)))
+		}
+		return nil
+	}
+
+	n := m.adopt(x, depth)
+	switch lx := searchNode(n, ly.key, depth); {
+	case m.op == setDifference:
+		scratch.recursiveDelete(&n, ly.key, depth)
+	case lx != nil:
+		scratch.recursiveInsert(&n, ly.key, m.merge(ly.key, lx.value, ly.value), depth)
+	default:
+		scratch.recursiveInsert(&n, ly.key, ly.value, depth)
+	}
+	return n
+}
+
+// combineSorted merges the sorted keys of trees which cannot be walked by nodes
+func (m *setMerger[V]) combineSorted(a, b Reader[V]) Tree[V] {
+	var keys []Key
+	var values []V
+	emit := func(key Key, value V) {
+		keys = append(keys, append(Key(nil), key...))
+		values = append(values, value)
+	}
+
+	ia, ib := a.Iterator(), b.Iterator()
+	na, _ := ia.Next()
+	nb, _ := ib.Next()
+	for na != nil || nb != nil {
+		cmp := 0
+		switch {
+		case na == nil:
+			cmp = 1
+		case nb == nil:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(na.Key(), nb.Key())
+		}
+
+		switch {
+		case cmp < 0:
+			if m.op != setIntersect {
+				emit(na.Key(), na.Value())
+			}
+			na, _ = ia.Next()
+		case cmp > 0:
+			if m.op == setUnion {
+				emit(nb.Key(), nb.Value())
+			}
+			nb, _ = ib.Next()
+		default:
+			if m.op != setDifference {
+				emit(na.Key(), m.merge(na.Key(), na.Value(), nb.Value()))
+			}
+			na, _ = ia.Next()
+			nb, _ = ib.Next()
+		}
+	}
+
+	return &tree[V]{root: buildSorted(keys, values, 0), size: len(keys)}
+}
+
+// rest returns the prefix bytes of an inner node which are not consumed, depth is where they start
+func (v setView[V]) rest(depth uint32) []byte {
+	node := v.n.node()
+	if node.prefixLen <= MaxPrefixLen {
+		return node.prefix[v.skip:node.prefixLen]
+	}
+	// the prefix is longer than stored, take it from any key under the node
+	return v.n.minimum().key[depth : depth-v.skip+node.prefixLen]
+}
+
+// branch returns the zeroChild and the children at the end of l bytes of rest,
+// a view whose prefix goes on has a single child, the view itself l+1 bytes further
+func (v setView[V]) branch(rest []byte, l int) (setView[V], []setChild[V]) {
+	if l < len(rest) {
+		return setView[V]{}, []setChild[V]{{rest[l], setView[V]{v.n, v.skip + uint32(l) + 1}}}
+	}
+
+	var children []setChild[V]
+	v.n.forEachChild(func(c byte, child *artNode[V]) bool {
+		children = append(children, setChild[V]{c, setView[V]{n: child}})
+		return true
+	})
+	return setView[V]{n: v.n.node().zeroChild}, children
+}
+
+// search searches key in the subtree of the view whose prefix starts at depth
+func (v setView[V]) search(key Key, depth uint32) *leaf[V] {
+	if v.n.isLeaf() {
+		if l := v.n.leaf(); l.match(key) {
+			return l
+		}
+		return nil
+	}
+	if int(depth) > len(key) {
+		return nil
+	}
+
+	rest := v.rest(depth)
+	if !bytes.HasPrefix(key[depth:], rest) {
+		return nil
+	}
+	depth += uint32(len(rest))
+	return searchNode(v.n.child(key.charAt(int(depth)), key.valid(int(depth))), key, depth+1)
+}
+
+// adopt returns the subtree of the view whose prefix starts at depth, it is shared when
+// the merger owns its nodes, then only a root whose prefix is partly consumed is copied
+func (m *setMerger[V]) adopt(v setView[V], depth uint32) *artNode[V] {
+	if v.n.isLeaf() || v.skip == 0 {
+		if m.owner != 0 {
+			return v.n
+		}
+		return cloneDeep(v.n)
+	}
+
+	rest := v.rest(depth)
+	var n *artNode[V]
+	if m.owner != 0 {
+		n = m.own(v.n.clone())
+	} else {
+		n = cloneDeep(v.n)
+	}
+	node := n.node()
+	node.prefixLen -= v.skip
+	copy(node.prefix[:], rest[:min(node.prefixLen, MaxPrefixLen)])
+	return n
+}
+
+// newSetNode returns a node with prefix and built children, a node with a single child is merged into it
+func (m *setMerger[V]) newSetNode(prefix []byte, zero *artNode[V], children []setChild[V]) *artNode[V] {
+	switch {
+	case len(children) == 0:
+		return zero
+	case len(children) == 1 && zero == nil:
+		return m.extend(children[0], prefix)
+	}
+
+	var an *artNode[V]
+	switch n := len(children); {
+	case n <= node4Max:
+		an = newNode4[V]()
+	case n <= node16Max:
+		an = newNode16[V]()
+	case n <= node48Max:
+		an = newNode48[V]()
+	default:
+		an = newNode256[V]()
+	}
+	an.setPrefix(prefix, uint32(len(prefix)))
+
+	node := an.node()
+	if zero != nil {
+		an.addChild(0, false, zero)
+		node.numKeys++
+	}
+	for _, child := range children {
+		an.addChild(child.c, true, child.v.n)
+		node.numKeys += child.v.n.keyCount()
+	}
+	return m.own(an)
+}
+
+// extend prepends prefix and the byte of a built child to the prefix of the child,
+// a shared child is copied first
+func (m *setMerger[V]) extend(c setChild[V], prefix []byte) *artNode[V] {
+	n := c.v.n
+	if n.isLeaf() {
+		return n
+	}
+	if m.owner != 0 && !n.owned(m.owner) {
+		n = m.own(n.clone())
+	}
+
+	node := n.node()
+	full := append(append(append([]byte(nil), prefix...), c.c), node.prefix[:min(node.prefixLen, MaxPrefixLen)]...)
+	node.prefixLen += uint32(len(prefix)) + 1
+	copy(node.prefix[:], full[:min(node.prefixLen, MaxPrefixLen)])
+	return n
+}
+
+// own marks a node created by a merger which shares nodes with its inputs as owned by the result
+func (m *setMerger[V]) own(n *artNode[V]) *artNode[V] {
+	return n.setOwner(m.owner)
+}
+
+// ownDeep marks a subtree created by the merger as owned by the result
+func (m *setMerger[V]) ownDeep(n *artNode[V]) *artNode[V] {
+	if m.owner == 0 || n == nil {
+		return n
+	}
+	m.own(n)
+	if !n.isLeaf() {
+		m.ownDeep(n.node().zeroChild)
+		n.forEachChild(func(_ byte, child *artNode[V]) bool {
+			m.ownDeep(child)
+			return true
+		})
+	}
+	return n
+}
+
+// cloneDeep copies a subtree
+func cloneDeep[V any](n *artNode[V]) *artNode[V] {
+	if n == nil {
+		return nil
+	}
+	n = n.clone()
+	if n.isLeaf() {
+		return n
+	}
+
+	node := n.node()
+	node.zeroChild = cloneDeep(node.zeroChild)
+	n.forEachChild(func(c byte, child *artNode[V]) bool {
+		*n.findChild(c, true) = cloneDeep(child)
+		return true
+	})
+	return n
+}
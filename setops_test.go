@@ -0,0 +1,213 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomSetKeys(r *rand.Rand, n int) map[string]int {
+	prefixes := []string{"", "a", "ab", "api.", "api.foo.", "this:key:has:a:long:common:prefix:", "this:key:has:a:long:prefix:", "z"}
+	keys := map[string]int{}
+	for len(keys) < n {
+		key := prefixes[r.Intn(len(prefixes))]
+		for i := r.Intn(4); i > 0; i-- {
+			key += string(rune('a' + r.Intn(20)))
+		}
+		keys[key] = r.Intn(1000)
+	}
+	return keys
+}
+
+func TestSetAlgebra(t *testing.T) {
+	merge := func(key Key, a, b int) int {
+		return a*1000 + b
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		ka, kb := randomSetKeys(r, r.Intn(60)), randomSetKeys(r, r.Intn(60))
+		a, ia, b := New[int](), NewImmutable[int](), NewImmutable[int]()
+		for k, v := range ka {
+			a.Insert(Key(k), v)
+			ia, _, _ = ia.Insert(Key(k), v)
+		}
+		for k, v := range kb {
+			b, _, _ = b.Insert(Key(k), v)
+		}
+		var frozen bytes.Buffer
+		_, err := WriteFrozen[int](&frozen, b, intCodec{})
+		assert.NoError(t, err)
+		fb, err := NewFrozen[int](frozen.Bytes(), intCodec{})
+		assert.NoError(t, err)
+		dump := func() string {
+			var values []int
+			for _, r := range []Reader[int]{a, ia, b} {
+				for it := r.Iterator(); it.HasNext(); {
+					n, _ := it.Next()
+					values = append(values, n.Value())
+				}
+			}
+			return fmt.Sprint(a.ForEachKeyPrefix(nil), ia.ForEachKeyPrefix(nil), b.ForEachKeyPrefix(nil), values)
+		}
+		before := dump()
+
+		union, intersect, difference := map[string]int{}, map[string]int{}, map[string]int{}
+		for k, v := range ka {
+			union[k] = v
+			if vb, ok := kb[k]; ok {
+				union[k] = merge(nil, v, vb)
+				intersect[k] = merge(nil, v, vb)
+			} else {
+				difference[k] = v
+			}
+		}
+		for k, v := range kb {
+			if _, ok := ka[k]; !ok {
+				union[k] = v
+			}
+		}
+
+		// b is walked by nodes as an ImmutableTree and by keys as a FrozenTree
+		for _, rb := range []Reader[int]{b, fb} {
+			assertSet(t, union, Union[int](a, rb, merge))
+			assertSet(t, intersect, Intersect[int](a, rb, merge))
+			assertSet(t, difference, Difference[int](a, rb))
+		}
+		// both immutable, nodes are shared
+		assertSet(t, union, Union[int](ia, b, merge))
+		assertSet(t, intersect, Intersect[int](ia, b, merge))
+		assertSet(t, difference, Difference[int](ia, b))
+
+		// modifying results leaves the operands unchanged
+		for _, result := range []Tree[int]{Union[int](a, b, merge), Union[int](ia, b, merge), Difference[int](ia, b)} {
+			for _, k := range result.ForEachKeyPrefix(nil) {
+				result.Insert(Key(k), -1)
+				result.Insert(Key(k+"!"), -1)
+			}
+			for _, k := range result.ForEachKeyPrefix(nil)[:result.Size()/2] {
+				result.Delete(Key(k))
+			}
+		}
+		assert.Equal(t, before, dump())
+	}
+}
+
+func TestSetAlgebraSharesImmutable(t *testing.T) {
+	a := NewImmutable[int]()
+	for i := 0; i < 100; i++ {
+		a, _, _ = a.Insert(Key(fmt.Sprintf("api.foo.%d", i)), i)
+	}
+	b, _, _ := NewImmutable[int]().Insert(Key("api.bar.1"), -1)
+	b, _, _ = b.Insert(Key("api.bar.2"), -1)
+
+	assert.Same(t, a.tree.root, Union[int](a, NewImmutable[int](), nil).(*tree[int]).root)
+	assert.Same(t, a.tree.root, Difference[int](a, NewImmutable[int]()).(*tree[int]).root)
+
+	// the root of a loses the consumed part of its prefix, all nodes below it are shared
+	nodes := nodeSet(a.tree.root)
+	result := Union[int](a, b, nil)
+	shared := 0
+	for ref := range nodeSet(result.(*tree[int]).root) {
+		if _, ok := nodes[ref]; ok {
+			shared++
+		}
+	}
+	assert.Equal(t, len(nodes)-1, shared)
+	assert.NoError(t, result.Validate())
+
+	// modifying the result copies the shared nodes on the path
+	result.Insert(Key("api.foo.5x"), -2)
+	result.Delete(Key("api.foo.1"))
+	assert.Equal(t, 102, result.Size())
+	assert.NoError(t, result.Validate())
+	assert.Equal(t, 100, a.Size())
+	assert.False(t, a.Contains(Key("api.foo.5x")))
+	assert.True(t, a.Contains(Key("api.foo.1")))
+	assert.NoError(t, a.tree.Validate())
+
+	// a mutable operand is copied
+	m := New[int]()
+	m.Insert(Key("api.foo.0"), 0)
+	m.Insert(Key("api.foo.1"), 1)
+	assert.NotSame(t, m.(*tree[int]).root, Union[int](m, NewImmutable[int](), nil).(*tree[int]).root)
+}
+
+func TestSetAlgebraResultInsertDelete(t *testing.T) {
+	a, _, _ := NewImmutable[int]().Insert(Key("a1"), 1)
+	b, _, _ := NewImmutable[int]().Insert(Key("a2"), 2)
+	result := Union[int](a, b, nil)
+
+	// nodes created by the result are then modified in place, the ones it shares are copied once
+	result.Insert(Key("a0"), 0)
+	root := result.(*tree[int]).root.ref
+	for i := 0; i < 100000; i++ {
+		key := Key(fmt.Sprintf("a%d", i%3+3))
+		result.Insert(key, i)
+		result.Delete(key)
+	}
+	result.Delete(Key("a0"))
+	assert.Equal(t, root, result.(*tree[int]).root.ref)
+	assert.Equal(t, 2, result.Size())
+	assert.NoError(t, result.Validate())
+	assert.Equal(t, []string{"a1"}, a.ForEachKeyPrefix(nil))
+	assert.Equal(t, []string{"a2"}, b.ForEachKeyPrefix(nil))
+}
+
+func nodeSet[V any](n *artNode[V]) map[unsafe.Pointer]struct{} {
+	nodes := map[unsafe.Pointer]struct{}{}
+	var walk func(n *artNode[V])
+	walk = func(n *artNode[V]) {
+		if n == nil {
+			return
+		}
+		nodes[n.ref] = struct{}{}
+		if !n.isLeaf() {
+			walk(n.node().zeroChild)
+			n.forEachChild(func(_ byte, child *artNode[V]) bool {
+				walk(child)
+				return true
+			})
+		}
+	}
+	walk(n)
+	return nodes
+}
+
+func assertSet(t *testing.T, expected map[string]int, result Tree[int]) {
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	assert.Equal(t, keys, result.ForEachKeyPrefix(nil))
+	assert.Equal(t, len(keys), result.Size())
+	for _, k := range keys {
+		v, found := result.Search(Key(k))
+		assert.True(t, found, k)
+		assert.Equal(t, expected[k], v, k)
+	}
+	assert.NoError(t, result.Validate())
+	if tr, ok := result.(*tree[int]); ok {
+		assertKeyCounts(t, tr.root)
+	}
+}
+
+func TestSetAlgebraDefaultMerge(t *testing.T) {
+	a, b := New[string](), New[string]()
+	a.Insert(Key("k"), "a")
+	b.Insert(Key("k"), "b")
+
+	v, _ := Union[string](a, b, nil).Search(Key("k"))
+	assert.Equal(t, "b", v)
+	v, _ = Intersect[string](a, b, nil).Search(Key("k"))
+	assert.Equal(t, "b", v)
+	assert.Equal(t, 0, Difference[string](a, b).Size())
+	assert.Equal(t, 0, Union[string](New[string](), New[string](), nil).Size())
+}
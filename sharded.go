@@ -0,0 +1,171 @@
+package art
+
+import (
+	"math/bits"
+)
+
+// maxShardPrefixLen keeps 256^prefixLen in an uint64
+const maxShardPrefixLen = 7
+
+// NewSharded returns a tree with n shards chosen by the first key byte.
+func NewSharded[V any](n int) *ShardedTree[V] {
+	return NewShardedPrefix[V](n, 1)
+}
+
+// NewShardedPrefix returns a tree with n shards chosen by the first prefixLen key bytes,
+// prefixLen is clamped to [1, 7]. A longer prefix spreads keys sharing their first byte.
+func NewShardedPrefix[V any](n, prefixLen int) *ShardedTree[V] {
+	if n < 1 {
+		n = 1
+	}
+	if prefixLen < 1 {
+		prefixLen = 1
+	}
+	if prefixLen > maxShardPrefixLen {
+		prefixLen = maxShardPrefixLen
+	}
+
+	t := &ShardedTree[V]{
+		shards:    make([]shard[V], n),
+		prefixLen: prefixLen,
+	}
+	for i := range t.shards {
+		t.shards[i].tree = &tree[V]{}
+	}
+	return t
+}
+
+// shardOf maps the leading bytes of key to a shard, missing bytes are taken as pad.
+// The leading bytes are read as a number in [0, 256^prefixLen) which is scaled to [0, n),
+// the mapping never decreases, so shards preserve the order of keys.
+func (t *ShardedTree[V]) shardOf(key Key, pad byte) int {
+	var value uint64
+	for i := 0; i < t.prefixLen; i++ {
+		c := pad
+		if i < len(key) {
+			c = key[i]
+		}
+		value = value<<8 | uint64(c)
+	}
+
+	hi, lo := bits.Mul64(value, uint64(len(t.shards)))
+	idx, _ := bits.Div64(hi, lo, 1<<(8*t.prefixLen))
+	return int(idx)
+}
+
+func (t *ShardedTree[V]) Insert(key Key, value V) (V, bool) {
+	s := &t.shards[t.shardOf(key, 0)]
+	s.Lock()
+	defer s.Unlock()
+	return s.tree.Insert(key, value)
+}
+
+func (t *ShardedTree[V]) Delete(key Key) (V, bool) {
+	s := &t.shards[t.shardOf(key, 0)]
+	s.Lock()
+	defer s.Unlock()
+	return s.tree.Delete(key)
+}
+
+func (t *ShardedTree[V]) Search(key Key) (V, bool) {
+	s := &t.shards[t.shardOf(key, 0)]
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.Search(key)
+}
+
+func (t *ShardedTree[V]) Contains(key Key) bool {
+	_, found := t.Search(key)
+	return found
+}
+
+// Size returns the number of keys, shards are counted one by one,
+// so the result may miss concurrent modifications.
+func (t *ShardedTree[V]) Size() int {
+	size := 0
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.RLock()
+		size += s.tree.Size()
+		s.RUnlock()
+	}
+	return size
+}
+
+// ForEachKeyPrefix returns keys under prefix in order,
+// only the shards which may hold such keys are visited.
+func (t *ShardedTree[V]) ForEachKeyPrefix(prefix Key) []string {
+	keys := make([]string, 0)
+	for i := t.shardOf(prefix, 0); i <= t.shardOf(prefix, 0xff); i++ {
+		s := &t.shards[i]
+		s.RLock()
+		keys = append(keys, s.tree.ForEachKeyPrefix(prefix)...)
+		s.RUnlock()
+	}
+	return keys
+}
+
+// Range visits keys between start and end in order, see Tree.Range for options.
+// A shard is read locked while its keys are visited, callback must not modify the tree.
+func (t *ShardedTree[V]) Range(start, end Key, callback Callback[V], options ...int) {
+	last := len(t.shards) - 1
+	if end != nil {
+		last = t.shardOf(end, 0)
+	}
+
+	for i := t.shardOf(start, 0); i <= last; i++ {
+		s := &t.shards[i]
+		stopped := false
+		s.RLock()
+		s.tree.Range(start, end, func(n Node[V]) bool {
+			stopped = !callback(n)
+			return !stopped
+		}, options...)
+		s.RUnlock()
+		if stopped {
+			return
+		}
+	}
+}
+
+// Iterator returns an iterator over keys in order. Shards are visited one at a time,
+// the keys of a shard are copied when the iterator reaches it.
+func (t *ShardedTree[V]) Iterator() Iterator[V] {
+	it := &shardedIterator[V]{tree: t}
+	it.fill()
+	return it
+}
+
+func (it *shardedIterator[V]) HasNext() bool {
+	return len(it.leaves) > 0
+}
+
+func (it *shardedIterator[V]) Next() (Node[V], error) {
+	if !it.HasNext() {
+		return nil, ErrNoMoreNodes
+	}
+
+	n := it.leaves[0]
+	it.leaves = it.leaves[1:]
+	if len(it.leaves) == 0 {
+		it.fill()
+	}
+	return n, nil
+}
+
+// fill copies leaves of the next non-empty shard
+func (it *shardedIterator[V]) fill() {
+	for len(it.leaves) == 0 && it.next < len(it.tree.shards) {
+		s := &it.tree.shards[it.next]
+		it.next++
+
+		s.RLock()
+		s.tree.recursiveForEach(s.tree.root, func(n Node[V]) bool {
+			if n.Type() == Leaf {
+				it.leaves = append(it.leaves, newLeaf(n.Key(), n.Value()))
+			}
+			return true
+		})
+		s.RUnlock()
+	}
+}
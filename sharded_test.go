@@ -0,0 +1,83 @@
+package art
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedTreeOrder(t *testing.T) {
+	keys := []string{"", "\x00", "a", "ab", "abc", "b", "m", "mz", "zz", "\xff", "\xff\xff"}
+	for i := 0; i < 256; i++ {
+		keys = append(keys, string([]byte{byte(i), 'k'}))
+	}
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	for _, tc := range []struct{ shards, prefixLen int }{{1, 1}, {3, 1}, {16, 1}, {300, 1}, {7, 2}, {1000, 7}} {
+		tree := NewShardedPrefix[string](tc.shards, tc.prefixLen)
+		for _, k := range keys {
+			tree.Insert(Key(k), k)
+		}
+		assert.Equal(t, len(keys), tree.Size())
+
+		// shards never decrease along sorted keys
+		for i := 1; i < len(sorted); i++ {
+			assert.LessOrEqual(t, tree.shardOf(Key(sorted[i-1]), 0), tree.shardOf(Key(sorted[i]), 0))
+		}
+
+		assert.Equal(t, sorted, tree.ForEachKeyPrefix(nil), tc)
+		assert.Equal(t, []string{"a", "ab", "abc", "ak"}, tree.ForEachKeyPrefix(Key("a")), tc)
+
+		var iterated []string
+		for it := tree.Iterator(); it.HasNext(); {
+			n, err := it.Next()
+			assert.NoError(t, err)
+			iterated = append(iterated, n.Key().String())
+		}
+		assert.Equal(t, sorted, iterated, tc)
+
+		var ranged []string
+		tree.Range(Key("ab"), Key("m"), func(n Node[string]) bool {
+			ranged = append(ranged, n.Key().String())
+			return len(ranged) < 5
+		}, RangeIncludeEnd)
+		assert.Equal(t, []string{"ab", "abc", "ak", "b", "bk"}, ranged, tc)
+
+		v, found := tree.Search(Key("mz"))
+		assert.True(t, found)
+		assert.Equal(t, "mz", v)
+		_, deleted := tree.Delete(Key("mz"))
+		assert.True(t, deleted)
+		assert.False(t, tree.Contains(Key("mz")))
+	}
+}
+
+func TestShardedTreeConcurrent(t *testing.T) {
+	tree := NewSharded[int](8)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := Key(fmt.Sprintf("%c%d:%d", 'a'+i%26, w, i))
+				tree.Insert(key, i)
+				if i%2 == 0 {
+					tree.Delete(key)
+				}
+				tree.Search(key)
+				if i%100 == 0 {
+					assert.True(t, sort.StringsAreSorted(tree.ForEachKeyPrefix(nil)))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8*500, tree.Size())
+}
@@ -0,0 +1,295 @@
+package art
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+/*
+	A snapshot is laid out as
+
+		magic "ARTS" | version byte | uvarint number of keys | entries | crc32
+
+	an entry is
+
+		uvarint shared | uvarint suffix length | suffix | uvarint value length | value
+
+	keys are written in order, shared is the length of the common prefix with the previous key.
+	crc32 is the Castagnoli checksum of all preceding bytes in little endian.
+*/
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (c GobCodec[V]) Marshal(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c GobCodec[V]) Unmarshal(data []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+func (e *SnapshotError) Error() string {
+	return fmt.Sprintf("art: snapshot offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *SnapshotError) Unwrap() error {
+	return e.Err
+}
+
+func (t *tree[V]) valueCodec() Codec[V] {
+	if t.codec == nil {
+		return GobCodec[V]{}
+	}
+	return t.codec
+}
+
+func (t *tree[V]) WriteTo(w io.Writer) (int64, error) {
+	sw := &snapshotWriter{crc: crc32.New(crcTable)}
+	sw.w = bufio.NewWriter(io.MultiWriter(countWriter{&sw.n, w}, sw.crc))
+
+	sw.w.WriteString(snapshotMagic)
+	sw.w.WriteByte(snapshotVersion)
+	sw.uvarint(uint64(t.Size()))
+
+	codec := t.valueCodec()
+	var prev Key
+	var err error
+	t.recursiveForEach(t.root, func(n Node[V]) bool {
+		if n.Type() != Leaf {
+			return true
+		}
+		key := n.Key()
+		var value []byte
+		if value, err = codec.Marshal(n.Value()); err != nil {
+			return false
+		}
+
+		shared := 0
+		for shared < len(prev) && shared < len(key) && prev[shared] == key[shared] {
+			shared++
+		}
+		sw.uvarint(uint64(shared))
+		sw.uvarint(uint64(len(key) - shared))
+		sw.w.Write(key[shared:])
+		sw.uvarint(uint64(len(value)))
+		sw.w.Write(value)
+		prev = key
+		return true
+	})
+	if err != nil {
+		return sw.n, err
+	}
+
+	// flush before the checksum is taken
+	if err := sw.w.Flush(); err != nil {
+		return sw.n, err
+	}
+	binary.Write(sw.w, binary.LittleEndian, sw.crc.Sum32())
+	err = sw.w.Flush()
+	return sw.n, err
+}
+
+func (t *tree[V]) ReadFrom(r io.Reader) (int64, error) {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = &unbufferedReader{Reader: r}
+	}
+	sr := &snapshotReader{r: br, crc: crc32.New(crcTable)}
+
+	magic, err := sr.bytes(uint64(len(snapshotMagic)))
+	if err != nil {
+		return sr.n, sr.error(ErrSnapshotFormat)
+	}
+	if string(magic) != snapshotMagic {
+		return sr.n, sr.error(ErrSnapshotFormat)
+	}
+	version, err := sr.bytes(1)
+	if err != nil {
+		return sr.n, err
+	}
+	if version[0] != snapshotVersion {
+		return sr.n, sr.error(ErrSnapshotVersion)
+	}
+	count, err := sr.uvarint()
+	if err != nil {
+		return sr.n, err
+	}
+
+	// count is not trusted before the checksum, so it does not size the slices
+	// values are decoded once the checksum is verified
+	keys := make([]Key, 0, min(uint32(count), 1<<16))
+	encoded := make([][]byte, 0, cap(keys))
+	offsets := make([]int64, 0, cap(keys))
+	var prev Key
+	for i := uint64(0); i < count; i++ {
+		shared, err := sr.uvarint()
+		if err != nil {
+			return sr.n, err
+		}
+		if shared > uint64(len(prev)) {
+			return sr.n, sr.error(ErrSnapshotCorrupt)
+		}
+		suffixLen, err := sr.uvarint()
+		if err != nil {
+			return sr.n, err
+		}
+		suffix, err := sr.bytes(suffixLen)
+		if err != nil {
+			return sr.n, err
+		}
+		key := append(append(make(Key, 0, int(shared)+len(suffix)), prev[:shared]...), suffix...)
+		if prev != nil && bytes.Compare(prev, key) >= 0 {
+			// keys are not sorted or not unique
+			return sr.n, sr.error(ErrSnapshotCorrupt)
+		}
+
+		valueLen, err := sr.uvarint()
+		if err != nil {
+			return sr.n, err
+		}
+		offset := sr.n
+		data, err := sr.bytes(valueLen)
+		if err != nil {
+			return sr.n, err
+		}
+
+		keys = append(keys, key)
+		encoded = append(encoded, data)
+		offsets = append(offsets, offset)
+		prev = key
+	}
+
+	sum := sr.crc.Sum32()
+	checksum, err := sr.bytes(4)
+	if err != nil {
+		return sr.n, err
+	}
+	if binary.LittleEndian.Uint32(checksum) != sum {
+		return sr.n, sr.error(ErrSnapshotChecksum)
+	}
+
+	codec := t.valueCodec()
+	values := make([]V, len(encoded))
+	for i, data := range encoded {
+		if values[i], err = codec.Unmarshal(data); err != nil {
+			return sr.n, &SnapshotError{Offset: offsets[i], Err: err}
+		}
+	}
+
+	t.root = buildSorted(keys, values, 0)
+	t.size = len(keys)
+	return sr.n, nil
+}
+
+type countWriter struct {
+	n *int64
+	w io.Writer
+}
+
+func (cw countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	*cw.n += int64(n)
+	return n, err
+}
+
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	n   int64
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (sw *snapshotWriter) uvarint(x uint64) {
+	sw.w.Write(sw.buf[:binary.PutUvarint(sw.buf[:], x)])
+}
+
+// snapshotReader reads a snapshot and checksums what it reads,
+// errors are returned as SnapshotError
+type snapshotReader struct {
+	r   byteReader
+	crc hash.Hash32
+	n   int64
+}
+
+func (sr *snapshotReader) error(err error) error {
+	return &SnapshotError{Offset: sr.n, Err: err}
+}
+
+func (sr *snapshotReader) ReadByte() (byte, error) {
+	c, err := sr.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	sr.crc.Write([]byte{c})
+	sr.n++
+	return c, nil
+}
+
+func (sr *snapshotReader) uvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(sr)
+	switch {
+	case err == nil:
+		return x, nil
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return 0, sr.error(ErrSnapshotTruncated)
+	default:
+		// the varint overflows
+		return 0, sr.error(ErrSnapshotCorrupt)
+	}
+}
+
+// bytes reads n bytes, the buffer grows with the data actually read,
+// so a corrupt length does not allocate more than the input
+func (sr *snapshotReader) bytes(n uint64) ([]byte, error) {
+	var buf bytes.Buffer
+	if n <= 1<<16 {
+		buf.Grow(int(n))
+	}
+	read, err := io.CopyN(io.MultiWriter(&buf, sr.crc), sr.r, int64(min64(n, 1<<62)))
+	sr.n += read
+	if uint64(read) < n {
+		return nil, sr.error(ErrSnapshotTruncated)
+	}
+	if err != nil {
+		return nil, sr.error(err)
+	}
+	return buf.Bytes(), nil
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// unbufferedReader reads bytes one by one, so nothing past the snapshot is consumed
+type unbufferedReader struct {
+	io.Reader
+	buf [1]byte
+}
+
+func (ur *unbufferedReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(ur.Reader, ur.buf[:]); err != nil {
+		return 0, err
+	}
+	return ur.buf[0], nil
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
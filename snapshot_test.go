@@ -0,0 +1,172 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type intCodec struct{}
+
+func (intCodec) Marshal(v int) ([]byte, error) {
+	return []byte(strconv.Itoa(v)), nil
+}
+
+func (intCodec) Unmarshal(data []byte) (int, error) {
+	return strconv.Atoi(string(data))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	keys := []string{"", "a", "ab", "abc", "api.foo", "api.foo.bar", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2"}
+	for i := 0; i < 256; i++ {
+		keys = append(keys, string([]byte{'k', byte(i)}), fmt.Sprintf("n%d", i))
+	}
+
+	for _, codec := range []Codec[int]{nil, intCodec{}} {
+		src := NewWithCodec[int](codec)
+		for i, k := range keys {
+			src.Insert(Key(k), i)
+		}
+
+		var buf bytes.Buffer
+		n, err := src.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		dst := NewWithCodec[int](codec).(*tree[int])
+		dst.Insert(Key("replaced"), 0)
+		n, err = dst.ReadFrom(bytes.NewReader(buf.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		assert.Equal(t, src.Size(), dst.Size())
+		assert.Equal(t, src.ForEachKeyPrefix(nil), dst.ForEachKeyPrefix(nil))
+		for i, k := range keys {
+			v, found := dst.Search(Key(k))
+			assert.True(t, found, k)
+			assert.Equal(t, i, v)
+		}
+		assertKeyCounts(t, dst.root)
+		assert.NoError(t, dst.Validate())
+
+		// the loaded tree is a regular tree
+		dst.Insert(Key("abd"), -1)
+		dst.Delete(Key("abc"))
+		assert.Equal(t, []string{"ab", "abd"}, dst.ForEachKeyPrefix(Key("ab")))
+		assertKeyCounts(t, dst.root)
+	}
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := New[string]().WriteTo(&buf)
+	assert.NoError(t, err)
+
+	tree := New[string]()
+	_, err = tree.ReadFrom(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tree.Size())
+}
+
+func TestSnapshotReadsNoFurther(t *testing.T) {
+	src := New[int]()
+	src.Insert(Key("a"), 1)
+	var buf bytes.Buffer
+	written, err := src.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	// the snapshot is followed by other data and r is not an io.ByteReader
+	r := bytes.NewReader(append(buf.Bytes(), "next"...))
+	dst := New[int]()
+	read, err := dst.ReadFrom(struct{ io.Reader }{r})
+	assert.NoError(t, err)
+	assert.Equal(t, written, read)
+	rest, _ := io.ReadAll(r)
+	assert.Equal(t, "next", string(rest))
+	assert.True(t, dst.Contains(Key("a")))
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	src := New[string]()
+	for _, k := range []string{"a", "ab", "b", "ba"} {
+		src.Insert(Key(k), k)
+	}
+	var buf bytes.Buffer
+	_, err := src.WriteTo(&buf)
+	assert.NoError(t, err)
+	data := buf.Bytes()
+
+	read := func(data []byte) error {
+		tree := New[string]()
+		tree.Insert(Key("kept"), "")
+		_, err := tree.ReadFrom(bytes.NewReader(data))
+		// a failed read leaves the tree unchanged
+		assert.Equal(t, []string{"kept"}, tree.ForEachKeyPrefix(nil))
+		var snapshotErr *SnapshotError
+		assert.True(t, errors.As(err, &snapshotErr), err)
+		return err
+	}
+
+	assert.ErrorIs(t, read(nil), ErrSnapshotFormat)
+	assert.ErrorIs(t, read([]byte("not a snapshot")), ErrSnapshotFormat)
+
+	version := append([]byte{}, data...)
+	version[len(snapshotMagic)] = 2
+	assert.ErrorIs(t, read(version), ErrSnapshotVersion)
+
+	for i := len(snapshotMagic); i < len(data); i++ {
+		assert.ErrorIs(t, read(data[:i]), ErrSnapshotTruncated, i)
+	}
+
+	// flip every bit, no input may panic or load
+	for i := len(snapshotMagic) + 1; i < len(data); i++ {
+		for bit := 0; bit < 8; bit++ {
+			corrupt := append([]byte{}, data...)
+			corrupt[i] ^= 1 << bit
+			read(corrupt)
+		}
+	}
+
+	checksum := append([]byte{}, data...)
+	checksum[len(checksum)-1] ^= 1
+	assert.ErrorIs(t, read(checksum), ErrSnapshotChecksum)
+
+	// a corrupt value is caught by the checksum before it is decoded
+	last, _ := GobCodec[string]{}.Marshal("ba")
+	for i := len(data) - 4 - len(last); i < len(data)-4; i++ {
+		for bit := 0; bit < 8; bit++ {
+			corrupt := append([]byte{}, data...)
+			corrupt[i] ^= 1 << bit
+			assert.ErrorIs(t, read(corrupt), ErrSnapshotChecksum, i)
+		}
+	}
+
+	// a key less than the previous one
+	value, _ := GobCodec[string]{}.Marshal("")
+	var unsorted bytes.Buffer
+	unsorted.WriteString(snapshotMagic)
+	unsorted.WriteByte(snapshotVersion)
+	unsorted.Write(binary.AppendUvarint(nil, 2))
+	for _, c := range []byte{'b', 'a'} {
+		for _, x := range []uint64{0, 1, uint64(c), uint64(len(value))} {
+			unsorted.Write(binary.AppendUvarint(nil, x))
+		}
+		unsorted.Write(value)
+	}
+	assert.ErrorIs(t, read(unsorted.Bytes()), ErrSnapshotCorrupt)
+
+	// a huge length is not allocated up front
+	var huge bytes.Buffer
+	huge.WriteString(snapshotMagic)
+	huge.WriteByte(snapshotVersion)
+	for _, x := range []uint64{1, 0, 1 << 60} {
+		huge.Write(binary.AppendUvarint(nil, x))
+	}
+	assert.ErrorIs(t, read(huge.Bytes()), ErrSnapshotTruncated)
+}
@@ -0,0 +1,52 @@
+package art
+
+func (t *tree[V]) Stats() Stats {
+	stats := Stats{
+		Nodes:      map[NodeType]int{},
+		PrefixLens: map[uint32]int{},
+		FillRatios: map[NodeType]float64{},
+	}
+
+	children := map[NodeType]int{}
+	depths := 0
+	var walk func(n *artNode[V], depth int)
+	walk = func(n *artNode[V], depth int) {
+		if n == nil {
+			return
+		}
+		if n.isLeaf() {
+			stats.Leaves++
+			depths += depth
+			if depth > stats.MaxDepth {
+				stats.MaxDepth = depth
+			}
+			return
+		}
+
+		node := n.node()
+		stats.Nodes[n._type]++
+		stats.PrefixLens[node.prefixLen]++
+		children[n._type] += int(node.numChildren)
+		if node.prefixLen > MaxPrefixLen {
+			stats.LongPrefixes++
+		}
+		if node.zeroChild != nil {
+			stats.ZeroChildren++
+			walk(node.zeroChild, depth+1)
+		}
+		n.forEachChild(func(_ byte, child *artNode[V]) bool {
+			walk(child, depth+1)
+			return true
+		})
+	}
+	walk(t.root, 0)
+
+	if stats.Leaves > 0 {
+		stats.AvgDepth = float64(depths) / float64(stats.Leaves)
+	}
+	capacity := map[NodeType]int{Node4: node4Max, Node16: node16Max, Node48: node48Max, Node256: node256Max}
+	for _type, count := range stats.Nodes {
+		stats.FillRatios[_type] = float64(children[_type]) / float64(count*capacity[_type])
+	}
+	return stats
+}
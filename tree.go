@@ -1,49 +1,80 @@
 package art
 
-func (t *tree) Size() int {
+import "bytes"
+
+func (t *tree[V]) Size() int {
 	if t == nil || t.root == nil {
 		return 0
 	}
 	return t.size
 }
 
-func (t *tree) Insert(key Key) bool {
-	updated := t.recursiveInsert(&t.root, key, 0)
+func (t *tree[V]) rootNode() *artNode[V] {
+	return t.root
+}
+
+func (t *tree[V]) Insert(key Key, value V) (V, bool) {
+	oldValue, updated := t.recursiveInsert(&t.root, key, value, 0)
 	if !updated {
 		t.size++
+		t.watchers.notify(OpInsert, key, oldValue, value)
+	} else {
+		t.watchers.notify(OpUpdate, key, oldValue, value)
 	}
-	return updated
+	return oldValue, updated
+}
+
+func (t *tree[V]) InsertString(key string, value V) (V, bool) {
+	return t.Insert(Key(key), value)
 }
 
-func (t *tree) recursiveInsert(curNode **artNode, key Key, depth uint32) bool {
+func (t *tree[V]) DeleteString(key string) (V, bool) {
+	return t.Delete(Key(key))
+}
+
+func (t *tree[V]) SearchString(key string) (V, bool) {
+	return t.Search(Key(key))
+}
+
+func (t *tree[V]) ContainsString(key string) bool {
+	return t.Contains(Key(key))
+}
+
+func (t *tree[V]) recursiveInsert(curNode **artNode[V], key Key, value V, depth uint32) (V, bool) {
+	var zero V
 	curr := *curNode
 	if curr == nil {
-		replaceRef(curNode, newLeaf(key))
-		return true
+		replaceRef(curNode, t.own(newLeaf(key, value)))
+		return zero, false
 	}
 
 	if curr.isLeaf() {
 		leaf := curr.leaf()
 
 		if leaf.match(key) {
-			return true
+			leaf = t.writable(curNode).leaf()
+			oldValue := leaf.value
+			leaf.value = value
+			return oldValue, true
 		}
 		// splilt leaf into new node4
-		newLeaf := newLeaf(key)
+		newLeaf := t.own(newLeaf(key, value))
 		leaf2 := newLeaf.leaf()
 		leafsLcp := longestCommonPrefix(leaf, leaf2, depth)
 
-		newNode := newNode4()
+		newNode := t.own(newNode4[V]())
 		newNode.setPrefix(key[depth:], leafsLcp)
+		newNode.node().numKeys = 2
 		depth += leafsLcp
 
 		newNode.addChild(leaf.key.charAt(int(depth)), leaf.key.valid(int(depth)), curr)
 		newNode.addChild(leaf2.key.charAt(int(depth)), leaf2.key.valid(int(depth)), newLeaf)
 		replaceRef(curNode, newNode)
 
-		return true
+		return zero, false
 	}
 
+	curr = t.writable(curNode)
 	node := curr.node()
 	if node.prefixLen > 0 {
 		prefixMismatchIdx := curr.matchDeep(key, depth)
@@ -54,9 +85,10 @@ func (t *tree) recursiveInsert(curNode **artNode, key Key, depth uint32) bool {
 		}
 
 		// new node as parent
-		newNode := newNode4()
+		newNode := t.own(newNode4[V]())
 		node4 := newNode.node()
 		node4.prefixLen = prefixMismatchIdx
+		node4.numKeys = node.numKeys + 1
 		for i := 0; i < int(min(prefixMismatchIdx, MaxPrefixLen)); i++ {
 			node4.prefix[i] = node.prefix[i]
 		}
@@ -79,35 +111,425 @@ func (t *tree) recursiveInsert(curNode **artNode, key Key, depth uint32) bool {
 			}
 		}
 
-		newNode.addChild(key.charAt(int(depth+prefixMismatchIdx)), key.valid(int(depth+prefixMismatchIdx)), newLeaf(key))
+		newNode.addChild(key.charAt(int(depth+prefixMismatchIdx)), key.valid(int(depth+prefixMismatchIdx)), t.own(newLeaf(key, value)))
 		replaceRef(curNode, newNode)
-		return true
+		return zero, false
 	}
 
 NEXT_NODE:
 	next := curr.findChild(key.charAt(int(depth)), key.valid(int(depth)))
-	if *next != nil {
-		return t.recursiveInsert(next, key, depth+1)
+	if next != nil && *next != nil {
+		oldValue, updated := t.recursiveInsert(next, key, value, depth+1)
+		if !updated {
+			curr.node().numKeys++
+		}
+		return oldValue, updated
+	}
+	// no child found, create new leaf, curr may grow
+	curr.addChild(key.charAt(int(depth)), key.valid(int(depth)), t.own(newLeaf(key, value)))
+	t.own(curr).node().numKeys++
+
+	return zero, false
+}
+
+func (t *tree[V]) Delete(key Key) (V, bool) {
+	value, deleted := t.recursiveDelete(&t.root, key, 0)
+	if deleted {
+		t.size--
+		var zero V
+		t.watchers.notify(OpDelete, key, value, zero)
+	}
+	return value, deleted
+}
+
+func (t *tree[V]) recursiveDelete(curNode **artNode[V], key Key, depth uint32) (V, bool) {
+	var zero V
+	curr := *curNode
+	if curr == nil {
+		return zero, false
+	}
+
+	if curr.isLeaf() {
+		leaf := curr.leaf()
+		if !leaf.match(key) {
+			return zero, false
+		}
+		replaceRef(curNode, nil)
+		return leaf.value, true
+	}
+
+	node := curr.node()
+	if node.prefixLen > 0 {
+		if curr.matchDeep(key, depth) < node.prefixLen {
+			return zero, false
+		}
+		depth += node.prefixLen
+	}
+
+	next := curr.findChild(key.charAt(int(depth)), key.valid(int(depth)))
+	if next == nil || *next == nil {
+		return zero, false
+	}
+
+	if (*next).isLeaf() {
+		leaf := (*next).leaf()
+		if !leaf.match(key) {
+			return zero, false
+		}
+		// removing child may shrink curr or collapse it into its last child
+		curr = t.writable(curNode)
+		curr.node().numKeys--
+		curr.removeChild(key.charAt(int(depth)), key.valid(int(depth)))
+		if !curr.isLeaf() {
+			// a collapsed leaf is still shared with others
+			t.own(curr)
+		}
+		return leaf.value, true
+	}
+
+	curr = t.writable(curNode)
+	next = curr.findChild(key.charAt(int(depth)), key.valid(int(depth)))
+	value, deleted := t.recursiveDelete(next, key, depth+1)
+	if deleted {
+		curr.node().numKeys--
+	}
+	return value, deleted
+}
+
+// writable returns the node referenced by ref which can be modified in place,
+// in a copy-on-write tree a node not owned by the tree is copied and the copy replaces it.
+func (t *tree[V]) writable(ref **artNode[V]) *artNode[V] {
+	n := *ref
+	if t.owner == 0 || n.owned(t.owner) {
+		return n
+	}
+	n = t.own(n.clone())
+	replaceRef(ref, n)
+	return n
+}
+
+// own marks a node created by a copy-on-write tree as owned by the tree
+func (t *tree[V]) own(n *artNode[V]) *artNode[V] {
+	return n.setOwner(t.owner)
+}
+
+func (t *tree[V]) Search(key Key) (V, bool) {
+	var zero V
+	leaf := t.search(key)
+	if leaf == nil {
+		return zero, false
+	}
+	return leaf.value, true
+}
+
+func (t *tree[V]) Contains(key Key) bool {
+	return t.search(key) != nil
+}
+
+// search finds the leaf which matches key exactly.
+// Only the prefix bytes kept in nodes are compared on the way down,
+// bytes of prefixes longer than MaxPrefixLen are skipped optimistically and
+// verified by a single full key compare at the leaf.
+func (t *tree[V]) search(key Key) *leaf[V] {
+	return searchNode(t.root, key, 0)
+}
+
+// searchNode searches key in the subtree of curr whose prefix starts at depth
+func searchNode[V any](curr *artNode[V], key Key, depth uint32) *leaf[V] {
+	for curr != nil {
+		if curr.isLeaf() {
+			leaf := curr.leaf()
+			if leaf.match(key) {
+				return leaf
+			}
+			return nil
+		}
+
+		node := curr.node()
+		if node.prefixLen > 0 {
+			if curr.match(key, depth) != min(node.prefixLen, MaxPrefixLen) {
+				return nil
+			}
+			depth += node.prefixLen
+		}
+
+		curr = curr.child(key.charAt(int(depth)), key.valid(int(depth)))
+		depth++
+	}
+
+	return nil
+}
+
+func (t *tree[V]) Floor(key Key) (Key, V, bool) {
+	return leafResult(t.floor(t.root, key, 0, false))
+}
+
+func (t *tree[V]) Ceiling(key Key) (Key, V, bool) {
+	return leafResult(t.ceiling(t.root, key, 0, false))
+}
+
+func (t *tree[V]) Predecessor(key Key) (Key, V, bool) {
+	return leafResult(t.floor(t.root, key, 0, true))
+}
+
+func (t *tree[V]) Successor(key Key) (Key, V, bool) {
+	return leafResult(t.ceiling(t.root, key, 0, true))
+}
+
+// floor finds the greatest leaf under curr which is less than key, or equal to key if not strict
+func (t *tree[V]) floor(curr *artNode[V], key Key, depth uint32, strict bool) *leaf[V] {
+	if curr == nil {
+		return nil
+	}
+
+	if curr.isLeaf() {
+		leaf := curr.leaf()
+		if c := bytes.Compare(leaf.key, key); c < 0 || (c == 0 && !strict) {
+			return leaf
+		}
+		return nil
+	}
+
+	node := curr.node()
+	if node.prefixLen > 0 {
+		switch compareBound(curr.prefixBytes(depth), key, depth) {
+		case 1:
+			return nil
+		case -1:
+			return curr.maximum()
+		}
+		depth += node.prefixLen
+	}
+
+	if !key.valid(int(depth)) {
+		// every key except zeroChild is greater than key
+		if strict {
+			return nil
+		}
+		return t.floor(node.zeroChild, key, depth, strict)
+	}
+
+	pos, child := curr.childNotLess(key[depth])
+	if child != nil && curr.keyAt(pos) == key[depth] {
+		if leaf := t.floor(child, key, depth+1, strict); leaf != nil {
+			return leaf
+		}
+	}
+	if child == nil {
+		// every child is less than key
+		pos = node256Max
+	}
+	if _, child := curr.childBefore(pos); child != nil {
+		return child.maximum()
+	}
+	// zeroChild is a prefix of key, so it is less than key
+	return t.floor(node.zeroChild, key, depth, strict)
+}
+
+// ceiling finds the least leaf under curr which is greater than key, or equal to key if not strict
+func (t *tree[V]) ceiling(curr *artNode[V], key Key, depth uint32, strict bool) *leaf[V] {
+	if curr == nil {
+		return nil
+	}
+
+	if curr.isLeaf() {
+		leaf := curr.leaf()
+		if c := bytes.Compare(leaf.key, key); c > 0 || (c == 0 && !strict) {
+			return leaf
+		}
+		return nil
+	}
+
+	node := curr.node()
+	if node.prefixLen > 0 {
+		switch compareBound(curr.prefixBytes(depth), key, depth) {
+		case 1:
+			return curr.minimum()
+		case -1:
+			return nil
+		}
+		depth += node.prefixLen
+	}
+
+	if !key.valid(int(depth)) {
+		// zeroChild equals key, other keys are greater
+		if leaf := t.ceiling(node.zeroChild, key, depth, strict); leaf != nil {
+			return leaf
+		}
+		if _, child := curr.childAfter(nullIdx); child != nil {
+			return child.minimum()
+		}
+		return nil
+	}
+
+	pos, child := curr.childNotLess(key[depth])
+	if child == nil {
+		return nil
+	}
+	if curr.keyAt(pos) == key[depth] {
+		if leaf := t.ceiling(child, key, depth+1, strict); leaf != nil {
+			return leaf
+		}
+		if _, child = curr.childAfter(pos); child == nil {
+			return nil
+		}
+	}
+	return child.minimum()
+}
+
+func (t *tree[V]) LongestPrefix(key Key) (Key, V, bool) {
+	curr := t.root
+	depth := uint32(0)
+	var longest *leaf[V]
+
+	for curr != nil {
+		if curr.isLeaf() {
+			if leaf := curr.leaf(); bytes.HasPrefix(key, leaf.key) {
+				longest = leaf
+			}
+			break
+		}
+
+		node := curr.node()
+		if node.prefixLen > 0 {
+			if curr.match(key, depth) != min(node.prefixLen, MaxPrefixLen) {
+				break
+			}
+			depth += node.prefixLen
+		}
+
+		// a key ends at this node, it is the deepest prefix so far
+		if node.zeroChild != nil {
+			if leaf := node.zeroChild.leaf(); bytes.HasPrefix(key, leaf.key) {
+				longest = leaf
+			}
+		}
+
+		if !key.valid(int(depth)) {
+			break
+		}
+		curr = curr.child(key[depth], true)
+		depth++
 	}
-	// no child found, create new leaf
-	curr.addChild(key.charAt(int(depth)), key.valid(int(depth)), newLeaf(key))
 
-	return true
+	return leafResult(longest)
 }
 
-func (t *tree) ForEachKeyPrefix(prefix Key) []string {
+func (t *tree[V]) Rank(key Key) int {
+	curr := t.root
+	depth := uint32(0)
+	rank := 0
+
+	for curr != nil {
+		if curr.isLeaf() {
+			if bytes.Compare(curr.leaf().key, key) < 0 {
+				rank++
+			}
+			return rank
+		}
+
+		node := curr.node()
+		if node.prefixLen > 0 {
+			switch compareBound(curr.prefixBytes(depth), key, depth) {
+			case 1:
+				return rank
+			case -1:
+				return rank + node.numKeys
+			}
+			depth += node.prefixLen
+		}
+
+		if !key.valid(int(depth)) {
+			// zeroChild equals key, other keys are greater
+			return rank
+		}
+
+		if node.zeroChild != nil {
+			rank++
+		}
+
+		var next *artNode[V]
+		c := key[depth]
+		curr.forEachChild(func(b byte, child *artNode[V]) bool {
+			if b < c {
+				rank += child.keyCount()
+				return true
+			}
+			if b == c {
+				next = child
+			}
+			return false
+		})
+		curr = next
+		depth++
+	}
+
+	return rank
+}
+
+func (t *tree[V]) Select(i int) (Key, V, bool) {
+	if i < 0 || i >= t.Size() {
+		return leafResult[V](nil)
+	}
+
+	curr := t.root
+	for !curr.isLeaf() {
+		node := curr.node()
+		if node.zeroChild != nil {
+			if i == 0 {
+				curr = node.zeroChild
+				break
+			}
+			i--
+		}
+
+		var next *artNode[V]
+		curr.forEachChild(func(_ byte, child *artNode[V]) bool {
+			if n := child.keyCount(); i >= n {
+				i -= n
+				return true
+			}
+			next = child
+			return false
+		})
+		curr = next
+	}
+
+	return leafResult(curr.leaf())
+}
+
+func (t *tree[V]) CountRange(start, end Key) int {
+	if end == nil {
+		return t.Size() - t.Rank(start)
+	}
+	if n := t.Rank(end) - t.Rank(start); n > 0 {
+		return n
+	}
+	return 0
+}
+
+func leafResult[V any](l *leaf[V]) (Key, V, bool) {
+	var zero V
+	if l == nil {
+		return nil, zero, false
+	}
+	return l.key, l.value, true
+}
+
+func (t *tree[V]) ForEachKeyPrefix(prefix Key) []string {
 	keys := make([]string, 0)
-	t.forEachPrefix(t.root, prefix, func(n Node) bool {
+	t.forEachPrefix(t.root, prefix, func(n Node[V]) bool {
 		if n.Type() != Leaf {
 			return true
 		}
 		keys = append(keys, n.Key().String())
 		return true
-	})
+	}, t.recursiveForEach)
 	return keys
 }
 
-func (t *tree) forEachPrefix(curr *artNode, key Key, callback Callback) traverseAction {
+// forEachPrefix finds the subtree under key and visits it with walk
+func (t *tree[V]) forEachPrefix(curr *artNode[V], key Key, callback Callback[V], walk func(*artNode[V], Callback[V]) traverseAction) traverseAction {
 	if curr == nil {
 		return traverseContinue
 	}
@@ -128,7 +550,7 @@ func (t *tree) forEachPrefix(curr *artNode, key Key, callback Callback) traverse
 		if depth == uint32(len(key)) {
 			leaf := curr.minimum()
 			if leaf.prefixMatch(key) {
-				if t.recursiveForEach(curr, callback) == traverseStop {
+				if walk(curr, callback) == traverseStop {
 					return traverseStop
 				}
 			}
@@ -145,23 +567,136 @@ func (t *tree) forEachPrefix(curr *artNode, key Key, callback Callback) traverse
 			if prefixLen == 0 {
 				break
 			} else if depth+prefixLen == uint32(len(key)) {
-				return t.recursiveForEach(curr, callback)
+				return walk(curr, callback)
 			}
 			depth += node.prefixLen
 		}
 
-		next := curr.findChild(key.charAt(int(depth)), key.valid(int(depth)))
-		if *next == nil {
+		next := curr.child(key.charAt(int(depth)), key.valid(int(depth)))
+		if next == nil {
 			break
 		}
-		curr = *next
+		curr = next
 		depth++
 	}
 
 	return traverseContinue
 }
 
-func (t *tree) recursiveForEach(curr *artNode, callback Callback) traverseAction {
+func (t *tree[V]) Range(start, end Key, callback Callback[V], options ...int) {
+	opts := 0
+	for _, opt := range options {
+		opts |= opt
+	}
+
+	bounds := &rangeBounds{
+		start:        start,
+		end:          end,
+		excludeStart: opts&RangeExcludeStart != 0,
+		includeEnd:   opts&RangeIncludeEnd != 0,
+	}
+	t.recursiveRange(t.root, 0, bounds, true, end != nil, callback)
+}
+
+// recursiveRange visits leaves between bounds in order,
+// lower and upper tell whether the path to curr still equals the start and end bound,
+// subtrees entirely out of bounds are skipped without being visited.
+func (t *tree[V]) recursiveRange(curr *artNode[V], depth uint32, bounds *rangeBounds, lower, upper bool, callback Callback[V]) traverseAction {
+	if curr == nil {
+		return traverseContinue
+	}
+
+	if curr.isLeaf() {
+		if bounds.contains(curr.leaf().key) && !callback(curr) {
+			return traverseStop
+		}
+		return traverseContinue
+	}
+
+	node := curr.node()
+	if node.prefixLen > 0 && (lower || upper) {
+		p := curr.prefixBytes(depth)
+		if lower {
+			switch compareBound(p, bounds.start, depth) {
+			case -1:
+				return traverseContinue
+			case 1:
+				lower = false
+			}
+		}
+		if upper {
+			switch compareBound(p, bounds.end, depth) {
+			case 1:
+				return traverseContinue
+			case -1:
+				upper = false
+			}
+		}
+	}
+	depth += node.prefixLen
+
+	if t.recursiveRange(node.zeroChild, depth+1, bounds, lower, upper, callback) == traverseStop {
+		return traverseStop
+	}
+
+	action := traverseContinue
+	curr.forEachChild(func(c byte, child *artNode[V]) bool {
+		childLower, childUpper := lower, upper
+		if lower {
+			switch compareBound([]byte{c}, bounds.start, depth) {
+			case -1:
+				return true
+			case 1:
+				childLower = false
+			}
+		}
+		if upper {
+			switch compareBound([]byte{c}, bounds.end, depth) {
+			case 1:
+				// children are in order, the rest are out of bounds too
+				return false
+			case -1:
+				childUpper = false
+			}
+		}
+
+		action = t.recursiveRange(child, depth+1, bounds, childLower, childUpper, callback)
+		return action != traverseStop
+	})
+
+	return action
+}
+
+func (b *rangeBounds) contains(key Key) bool {
+	if c := bytes.Compare(key, b.start); c < 0 || (c == 0 && b.excludeStart) {
+		return false
+	}
+	if b.end == nil {
+		return true
+	}
+	c := bytes.Compare(key, b.end)
+	return c < 0 || (c == 0 && b.includeEnd)
+}
+
+// compareBound compares path bytes p found at depth with the same bytes of bound.
+// A bound which ends before p is less than every key under p.
+func compareBound(p []byte, bound Key, depth uint32) int {
+	for i := range p {
+		pos := int(depth) + i
+		if pos >= len(bound) {
+			return 1
+		}
+		if p[i] != bound[pos] {
+			if p[i] < bound[pos] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (t *tree[V]) recursiveForEach(curr *artNode[V], callback Callback[V]) traverseAction {
 	if curr == nil {
 		return traverseContinue
 	}
@@ -205,7 +740,7 @@ func (t *tree) recursiveForEach(curr *artNode, callback Callback) traverseAction
 	return traverseContinue
 }
 
-func (t *tree) forEachChildren(nullChild *artNode, children []*artNode, callback Callback) traverseAction {
+func (t *tree[V]) forEachChildren(nullChild *artNode[V], children []*artNode[V], callback Callback[V]) traverseAction {
 	if nullChild != nil {
 		if t.recursiveForEach(nullChild, callback) == traverseStop {
 			return traverseStop
@@ -222,30 +757,89 @@ func (t *tree) forEachChildren(nullChild *artNode, children []*artNode, callback
 	return traverseContinue
 }
 
-func (t *tree) Iterator() Iterator {
-	return &iterator{
+func (t *tree[V]) Descend(callback Callback[V]) {
+	t.recursiveDescend(t.root, callback)
+}
+
+func (t *tree[V]) DescendPrefix(prefix Key, callback Callback[V]) {
+	t.forEachPrefix(t.root, prefix, callback, t.recursiveDescend)
+}
+
+// recursiveDescend visits leaves from the greatest key to the least,
+// zeroChild of a node is less than any other child, so it comes last.
+func (t *tree[V]) recursiveDescend(curr *artNode[V], callback Callback[V]) traverseAction {
+	if curr == nil {
+		return traverseContinue
+	}
+
+	if curr.isLeaf() {
+		if !callback(curr) {
+			return traverseStop
+		}
+		return traverseContinue
+	}
+
+	for idx, child := curr.childBefore(node256Max); child != nil; idx, child = curr.childBefore(idx) {
+		if t.recursiveDescend(child, callback) == traverseStop {
+			return traverseStop
+		}
+	}
+
+	return t.recursiveDescend(curr.node().zeroChild, callback)
+}
+
+func (t *tree[V]) Iterator(options ...int) Iterator[V] {
+	opts := 0
+	for _, opt := range options {
+		opts |= opt
+	}
+	if opts&TraverseAll == 0 {
+		opts = TraverseLeaf
+	}
+
+	it := &iterator[V]{
 		tree:       t,
+		options:    opts,
 		nextNode:   t.root,
 		depthLevel: 0,
-		depth:      []*iteratorLevel{{t.root, nullIdx}},
+		depth:      []*iteratorLevel[V]{{t.root, nullIdx}},
+	}
+	if it.nextNode != nil && !it.matches(it.nextNode) {
+		it.advance()
 	}
+	return it
 }
 
-func (it *iterator) HasNext() bool {
+func (it *iterator[V]) HasNext() bool {
 	return it != nil && it.nextNode != nil
 }
 
-func (it *iterator) Next() (Node, error) {
+func (it *iterator[V]) Next() (Node[V], error) {
 	if !it.HasNext() {
 		return nil, ErrNoMoreNodes
 	}
 	cur := it.nextNode
-	it.next()
+	it.advance()
 	return cur, nil
 }
 
-func (it *iterator) next() {
-	var nextNode *artNode
+// advance moves to the next node which matches options of the iterator
+func (it *iterator[V]) advance() {
+	it.next()
+	for it.nextNode != nil && !it.matches(it.nextNode) {
+		it.next()
+	}
+}
+
+func (it *iterator[V]) matches(n *artNode[V]) bool {
+	if n.isLeaf() {
+		return it.options&TraverseLeaf != 0
+	}
+	return it.options&TraverseNode != 0
+}
+
+func (it *iterator[V]) next() {
+	var nextNode *artNode[V]
 	for {
 		nextChildIdx := nullIdx
 
@@ -298,19 +892,19 @@ func (it *iterator) next() {
 			it.nextNode = nextNode
 
 			if it.depthLevel+1 >= cap(it.depth) {
-				newDepthLevel := make([]*iteratorLevel, it.depthLevel+2)
+				newDepthLevel := make([]*iteratorLevel[V], it.depthLevel+2)
 				copy(newDepthLevel, it.depth)
 				it.depth = newDepthLevel
 			}
 
 			it.depthLevel++
-			it.depth[it.depthLevel] = &iteratorLevel{nextNode, nullIdx}
+			it.depth[it.depthLevel] = &iteratorLevel[V]{nextNode, nullIdx}
 			return
 		}
 	}
 }
 
-func nextChild(childIdx int, nullChild *artNode, children []*artNode) (int, *artNode) {
+func nextChild[V any](childIdx int, nullChild *artNode[V], children []*artNode[V]) (int, *artNode[V]) {
 	if childIdx == nullIdx {
 		if nullChild != nil {
 			return 0, nullChild
@@ -327,3 +921,60 @@ func nextChild(childIdx int, nullChild *artNode, children []*artNode) (int, *art
 
 	return 0, nil
 }
+
+func (t *tree[V]) ReverseIterator() Iterator[V] {
+	it := &reverseIterator[V]{}
+	if t.root == nil {
+		return it
+	}
+	if t.root.isLeaf() {
+		it.nextNode = t.root
+		return it
+	}
+
+	it.depth = []*iteratorLevel[V]{{t.root, node256Max}}
+	it.next()
+	return it
+}
+
+func (it *reverseIterator[V]) HasNext() bool {
+	return it != nil && it.nextNode != nil
+}
+
+func (it *reverseIterator[V]) Next() (Node[V], error) {
+	if !it.HasNext() {
+		return nil, ErrNoMoreNodes
+	}
+	cur := it.nextNode
+	it.next()
+	return cur, nil
+}
+
+func (it *reverseIterator[V]) next() {
+	for len(it.depth) > 0 {
+		level := it.depth[len(it.depth)-1]
+
+		var child *artNode[V]
+		if level.childIdx >= 0 {
+			level.childIdx, child = level.node.childBefore(level.childIdx)
+		}
+		if child == nil && level.childIdx == nullIdx {
+			// all other children visited, zeroChild is the last
+			child = level.node.node().zeroChild
+			level.childIdx = doneIdx
+		}
+
+		if child == nil {
+			it.depth = it.depth[:len(it.depth)-1]
+			continue
+		}
+
+		if child.isLeaf() {
+			it.nextNode = child
+			return
+		}
+		it.depth = append(it.depth, &iteratorLevel[V]{child, node256Max})
+	}
+
+	it.nextNode = nil
+}
@@ -2,6 +2,7 @@ package art
 
 import (
 	"fmt"
+	"math/rand"
 	"sort"
 	"strings"
 	"testing"
@@ -79,9 +80,9 @@ func TestTreeTraversalPrefix(t *testing.T) {
 	}
 
 	for _, d := range dataSet {
-		tree := New()
+		tree := New[string]()
 		for _, k := range d.keys {
-			tree.Insert(Key(k))
+			tree.Insert(Key(k), k)
 		}
 
 		actual := tree.ForEachKeyPrefix(Key(d.keyPrefix))
@@ -94,11 +95,11 @@ func TestTreeTraversalPrefix(t *testing.T) {
 }
 
 func TestTreeIterator(t *testing.T) {
-	tree := New()
-	tree.Insert(Key("2"))
-	tree.Insert(Key("1"))
+	tree := New[int]()
+	tree.Insert(Key("2"), 2)
+	tree.Insert(Key("1"), 1)
 
-	it := tree.Iterator()
+	it := tree.Iterator(TraverseAll)
 	assert.NotNil(t, it)
 	assert.True(t, it.HasNext())
 	n4, err := it.Next()
@@ -122,6 +123,610 @@ func TestTreeIterator(t *testing.T) {
 
 }
 
+func TestTreeIteratorOptions(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba"}
+	for i := 0; i < node256Max; i += 6 {
+		keys = append(keys, string([]byte{'y', byte(i)}))
+	}
+
+	tree := New[string]()
+	assert.False(t, tree.Iterator().HasNext())
+
+	for _, k := range keys {
+		tree.Insert(Key(k), k)
+	}
+	sort.Strings(keys)
+
+	collect := func(it Iterator[string]) ([]string, map[NodeType]int) {
+		leaves, types := make([]string, 0), map[NodeType]int{}
+		for it.HasNext() {
+			n, err := it.Next()
+			assert.NoError(t, err)
+			types[n.Type()]++
+			if n.Type() == Leaf {
+				assert.Equal(t, n.Key().String(), n.Value())
+				leaves = append(leaves, n.Key().String())
+			}
+		}
+		return leaves, types
+	}
+
+	leaves, types := collect(tree.Iterator())
+	assert.Equal(t, keys, leaves)
+	assert.Equal(t, map[NodeType]int{Leaf: len(keys)}, types)
+
+	leaves, types = collect(tree.Iterator(TraverseNode))
+	assert.Empty(t, leaves)
+	assert.Equal(t, 0, types[Leaf])
+	assert.Equal(t, 1, types[Node48])
+
+	leaves, types = collect(tree.Iterator(TraverseAll))
+	assert.Equal(t, keys, leaves)
+	assert.Equal(t, len(keys), types[Leaf])
+	assert.Equal(t, 1, types[Node48])
+
+	single := New[string]()
+	single.Insert(Key("k"), "k")
+	assert.False(t, single.Iterator(TraverseNode).HasNext())
+	leaves, _ = collect(single.Iterator())
+	assert.Equal(t, []string{"k"}, leaves)
+}
+
+func TestTreeInsertSearch(t *testing.T) {
+	keys := []string{"api.foo.bar", "api.foo.baz", "api.foe.fum", "abc.123.456", "api.foo", "api", "this:key:has:a:long:prefix:3", "this:key:has:a:long:common:prefix:2"}
+
+	tree := New[int]()
+	for i, k := range keys {
+		old, updated := tree.Insert(Key(k), i)
+		assert.False(t, updated, k)
+		assert.Zero(t, old, k)
+	}
+	assert.Equal(t, len(keys), tree.Size())
+
+	for i, k := range keys {
+		v, found := tree.Search(Key(k))
+		assert.True(t, found, k)
+		assert.Equal(t, i, v, k)
+	}
+
+	for _, k := range []string{"", "a", "ap", "api.", "api.foo.ba", "api.foo.bar.", "this:key:has:a:long:prefix:"} {
+		v, found := tree.Search(Key(k))
+		assert.False(t, found, k)
+		assert.Zero(t, v, k)
+	}
+
+	old, updated := tree.Insert(Key("api"), 100)
+	assert.True(t, updated)
+	assert.Equal(t, 5, old)
+	assert.Equal(t, len(keys), tree.Size())
+
+	v, found := tree.Search(Key("api"))
+	assert.True(t, found)
+	assert.Equal(t, 100, v)
+
+	it := tree.Iterator()
+	for it.HasNext() {
+		n, err := it.Next()
+		assert.NoError(t, err)
+		if n.Type() == Leaf && n.Key().String() == "api" {
+			assert.Equal(t, 100, n.Value())
+		}
+	}
+}
+
+func TestTreeInsertZeroChildFullNode(t *testing.T) {
+	// zeroChild does not take a slot, adding it to a full node does not grow the node
+	tr := New[int]().(*tree[int])
+	for _, k := range []string{"ka", "kb", "kc", "kd", "k"} {
+		tr.Insert(Key(k), 0)
+	}
+	assert.Equal(t, Node4, tr.root.Type())
+	assert.Equal(t, uint16(node4Max), tr.root.node().numChildren)
+	assert.NotNil(t, tr.root.node().zeroChild)
+	for _, k := range []string{"ka", "kb", "kc", "kd", "k"} {
+		assert.True(t, tr.Contains(Key(k)), k)
+	}
+}
+
+func TestTreeStringKeys(t *testing.T) {
+	type user struct {
+		name string
+		age  int
+	}
+
+	tree := New[user]()
+	_, updated := tree.InsertString("u:1", user{"alice", 30})
+	assert.False(t, updated)
+	old, updated := tree.InsertString("u:1", user{"alice", 31})
+	assert.True(t, updated)
+	assert.Equal(t, 30, old.age)
+
+	u, found := tree.SearchString("u:1")
+	assert.True(t, found)
+	assert.Equal(t, user{"alice", 31}, u)
+	assert.True(t, tree.ContainsString("u:1"))
+
+	u, deleted := tree.DeleteString("u:1")
+	assert.True(t, deleted)
+	assert.Equal(t, "alice", u.name)
+	assert.False(t, tree.ContainsString("u:1"))
+
+	u, found = tree.SearchString("u:1")
+	assert.False(t, found)
+	assert.Equal(t, user{}, u)
+}
+
+func TestTreeContains(t *testing.T) {
+	keys := []string{
+		"this:key:has:a:long:prefix:3",
+		"this:key:has:a:long:common:prefix:2",
+		"this:key:has:a:long:common:prefix:1",
+		"this:key:has:a:long:common:prefix",
+		"this",
+	}
+
+	tree := New[bool]()
+	for _, k := range keys {
+		tree.Insert(Key(k), true)
+	}
+
+	for _, k := range keys {
+		assert.True(t, tree.Contains(Key(k)), k)
+	}
+
+	for _, k := range []string{
+		"",
+		"this:",
+		"this:key:has:a:long:",
+		// differ after the first MaxPrefixLen bytes of a compressed prefix
+		"this:key:has:a:short:common:prefix:1",
+		"this:key:has:a:long:common:prefix:",
+		"this:key:has:a:long:common:prefix:10",
+		"this:key:has:a:long:common:prefiz:1",
+	} {
+		assert.False(t, tree.Contains(Key(k)), k)
+	}
+}
+
+func TestTreeDelete(t *testing.T) {
+	keys := []string{"api.foo.bar", "api.foo.baz", "api.foe.fum", "abc.123.456", "api.foo", "api"}
+
+	tree := New[int]()
+	for i, k := range keys {
+		tree.Insert(Key(k), i)
+	}
+
+	v, deleted := tree.Delete(Key("ap"))
+	assert.False(t, deleted)
+	assert.Zero(t, v)
+	assert.Equal(t, len(keys), tree.Size())
+
+	for i, k := range keys {
+		v, deleted := tree.Delete(Key(k))
+		assert.True(t, deleted, k)
+		assert.Equal(t, i, v, k)
+		assert.Equal(t, len(keys)-i-1, tree.Size())
+
+		_, found := tree.Search(Key(k))
+		assert.False(t, found, k)
+
+		for _, rest := range keys[i+1:] {
+			_, found := tree.Search(Key(rest))
+			assert.True(t, found, rest)
+		}
+		expected := append([]string{}, keys[i+1:]...)
+		actual := tree.ForEachKeyPrefix(nil)
+		sort.Strings(expected)
+		sort.Strings(actual)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, deleted = tree.Delete(Key("api"))
+	assert.False(t, deleted)
+	assert.Equal(t, 0, tree.Size())
+}
+
+func TestTreeDeleteShrink(t *testing.T) {
+	tree := New[int]().(*tree[int])
+	for i := 0; i < node256Max; i++ {
+		tree.Insert(Key{'k', byte(i)}, i)
+	}
+	tree.Insert(Key("k"), -1)
+	assert.Equal(t, Node256, tree.root.Type())
+
+	expected := []struct {
+		remain int
+		typ    NodeType
+	}{
+		{node256Min, Node256},
+		{node48Max, Node48},
+		{node48Min, Node48},
+		{node16Max, Node16},
+		{node16Min, Node16},
+		{node4Max, Node4},
+		{node4Min, Node4},
+	}
+
+	remain := node256Max
+	for _, e := range expected {
+		for ; remain > e.remain; remain-- {
+			_, deleted := tree.Delete(Key{'k', byte(remain - 1)})
+			assert.True(t, deleted)
+		}
+		assert.Equal(t, e.typ, tree.root.Type(), e.remain)
+		assert.Equal(t, e.remain+1, tree.Size())
+		for i := 0; i < e.remain; i++ {
+			v, found := tree.Search(Key{'k', byte(i)})
+			assert.True(t, found)
+			assert.Equal(t, i, v)
+		}
+	}
+
+	// node4 left with the zero child and one leaf
+	tree.Delete(Key{'k', 1})
+	assert.Equal(t, Node4, tree.root.Type())
+	tree.Delete(Key("k"))
+	assert.Equal(t, Leaf, tree.root.Type())
+	assert.Equal(t, Key{'k', 0}, tree.root.Key())
+}
+
+func TestTreeDeleteCompressPrefix(t *testing.T) {
+	tree := New[int]().(*tree[int])
+	tree.Insert(Key("abcdefghijklmn:1"), 1)
+	tree.Insert(Key("abcdefghijklmn:2"), 2)
+	tree.Insert(Key("abcdefghijklmn"), 0)
+	tree.Insert(Key("abc"), 3)
+
+	assert.Equal(t, uint32(3), tree.root.node().prefixLen)
+
+	tree.Delete(Key("abc"))
+	assert.Equal(t, Node4, tree.root.Type())
+	assert.Equal(t, uint32(len("abcdefghijklmn")), tree.root.node().prefixLen)
+	assert.Equal(t, "abcdefghij", string(tree.root.node().prefix[:]))
+
+	tree.Delete(Key("abcdefghijklmn"))
+	assert.Equal(t, uint32(len("abcdefghijklmn:")), tree.root.node().prefixLen)
+
+	for _, k := range []string{"abcdefghijklmn:1", "abcdefghijklmn:2"} {
+		_, found := tree.Search(Key(k))
+		assert.True(t, found, k)
+	}
+	assert.Equal(t, []string{"abcdefghijklmn:1", "abcdefghijklmn:2"}, tree.ForEachKeyPrefix(Key("abcdefghijklmn")))
+
+	tree.Insert(Key("abcdefghijklmx"), 4)
+	_, found := tree.Search(Key("abcdefghijklmx"))
+	assert.True(t, found)
+	assert.Equal(t, 3, tree.Size())
+}
+
+func TestBigKeySetDelete(t *testing.T) {
+	keys := getKeys("1mvl5_10")
+
+	tree := New[string]().(*tree[string])
+	for _, k := range keys {
+		tree.Insert(Key(k), k)
+	}
+
+	for i, k := range keys {
+		if i%2 == 0 {
+			_, deleted := tree.Delete(Key(k))
+			assert.True(t, deleted, k)
+		}
+	}
+	assert.Equal(t, len(keys)/2, tree.Size())
+	assertKeyCounts(t, tree.root)
+
+	for i, k := range keys {
+		v, found := tree.Search(Key(k))
+		if i%2 == 0 {
+			assert.False(t, found, k)
+		} else {
+			assert.True(t, found, k)
+			assert.Equal(t, k, v)
+		}
+	}
+}
+
+func TestTreeRange(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3"}
+	for i := 0; i < node256Max; i++ {
+		keys = append(keys, string([]byte{'z', byte(i)}))
+	}
+
+	tree := New[string]()
+	for _, k := range keys {
+		tree.Insert(Key(k), k)
+	}
+	sort.Strings(keys)
+
+	rangeKeys := func(start, end Key, options ...int) []string {
+		res := make([]string, 0)
+		tree.Range(start, end, func(n Node[string]) bool {
+			assert.Equal(t, Leaf, n.Type())
+			res = append(res, n.Key().String())
+			return true
+		}, options...)
+		return res
+	}
+
+	expectedKeys := func(start, end Key, excludeStart, includeEnd bool) []string {
+		res := make([]string, 0)
+		for _, k := range keys {
+			if k < string(start) || (excludeStart && k == string(start)) {
+				continue
+			}
+			if end != nil && (k > string(end) || (!includeEnd && k == string(end))) {
+				continue
+			}
+			res = append(res, k)
+		}
+		return res
+	}
+
+	assert.Equal(t, keys, rangeKeys(nil, nil))
+
+	bounds := []string{"", "a", "ab", "api", "api.", "api.foo", "api.foo.bb", "az", "b", "this:key:has:a:long:", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:d", "z", "z\x05", "z\x80\x00", "zz"}
+	for _, start := range bounds {
+		for _, end := range bounds {
+			for _, opt := range []int{0, RangeExcludeStart, RangeIncludeEnd, RangeExcludeStart | RangeIncludeEnd} {
+				excludeStart, includeEnd := opt&RangeExcludeStart != 0, opt&RangeIncludeEnd != 0
+				assert.Equal(t, expectedKeys(Key(start), Key(end), excludeStart, includeEnd), rangeKeys(Key(start), Key(end), opt), "%q %q %d", start, end, opt)
+			}
+		}
+		assert.Equal(t, expectedKeys(Key(start), nil, false, false), rangeKeys(Key(start), nil), "%q", start)
+	}
+
+	// stop early
+	res := make([]string, 0)
+	tree.Range(Key("api"), nil, func(n Node[string]) bool {
+		res = append(res, n.Key().String())
+		return len(res) < 3
+	})
+	assert.Equal(t, []string{"api", "api.foe.fum", "api.foo"}, res)
+}
+
+func TestTreeDescend(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3"}
+	for i := 0; i < node256Max; i += 3 {
+		keys = append(keys, string([]byte{'y', byte(i)}))
+	}
+	for i := 0; i < node256Max; i++ {
+		keys = append(keys, string([]byte{'z', byte(i)}))
+	}
+
+	tree := New[string]()
+	for _, k := range keys {
+		tree.Insert(Key(k), k)
+	}
+
+	reversed := func(keys []string) []string {
+		res := make([]string, 0, len(keys))
+		for i := len(keys) - 1; i >= 0; i-- {
+			res = append(res, keys[i])
+		}
+		return res
+	}
+	sort.Strings(keys)
+
+	descended := make([]string, 0)
+	tree.Descend(func(n Node[string]) bool {
+		descended = append(descended, n.Key().String())
+		return true
+	})
+	assert.Equal(t, reversed(keys), descended)
+
+	iterated := make([]string, 0)
+	for it := tree.ReverseIterator(); it.HasNext(); {
+		n, err := it.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, n.Value(), n.Key().String())
+		iterated = append(iterated, n.Key().String())
+	}
+	assert.Equal(t, reversed(keys), iterated)
+
+	for _, prefix := range []string{"", "a", "api", "api.foo", "this:key:has:a:long", "y", "z\x10", "c"} {
+		expected := make([]string, 0)
+		for _, k := range keys {
+			if strings.HasPrefix(k, prefix) {
+				expected = append(expected, k)
+			}
+		}
+
+		descended := make([]string, 0)
+		tree.DescendPrefix(Key(prefix), func(n Node[string]) bool {
+			descended = append(descended, n.Key().String())
+			return true
+		})
+		assert.Equal(t, reversed(expected), descended, prefix)
+	}
+
+	latest := make([]string, 0)
+	tree.DescendPrefix(Key("api"), func(n Node[string]) bool {
+		latest = append(latest, n.Key().String())
+		return len(latest) < 2
+	})
+	assert.Equal(t, []string{"api.foo.baz", "api.foo.bar"}, latest)
+
+	empty := New[string]()
+	assert.False(t, empty.ReverseIterator().HasNext())
+	empty.Insert(Key("k"), "k")
+	it := empty.ReverseIterator()
+	n, err := it.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Key("k"), n.Key())
+	_, err = it.Next()
+	assert.Equal(t, ErrNoMoreNodes, err)
+}
+
+func TestTreeFloorCeiling(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3"}
+	for i := 1; i < node256Max; i += 6 {
+		keys = append(keys, string([]byte{'x', byte(i)}))
+	}
+	for i := 1; i < node256Max; i += 2 {
+		keys = append(keys, string([]byte{'y', byte(i)}))
+	}
+
+	tree := New[string]()
+	_, _, found := tree.Floor(Key("a"))
+	assert.False(t, found)
+
+	for _, k := range keys {
+		tree.Insert(Key(k), k)
+	}
+	sort.Strings(keys)
+
+	probes := append([]string{"ab", "api.", "api.foo.bb", "az", "bb", "c", "this:key:has:a:long:", "this:key:has:a:long:d", "this:key:has:a:long:common:prefix:10", "x", "x\x00", "x\x03", "x\xff", "y\x00", "y\x02", "y\xff", "zz", "\x00"}, keys...)
+	check := func(name string, probe string, idx int, get func(Key) (Key, string, bool)) {
+		key, value, found := get(Key(probe))
+		if idx < 0 || idx >= len(keys) {
+			assert.False(t, found, "%s %q", name, probe)
+			assert.Nil(t, key)
+			return
+		}
+		assert.True(t, found, "%s %q", name, probe)
+		assert.Equal(t, keys[idx], key.String(), "%s %q", name, probe)
+		assert.Equal(t, keys[idx], value, "%s %q", name, probe)
+	}
+
+	for _, probe := range probes {
+		ceiling := sort.SearchStrings(keys, probe)
+		successor := ceiling
+		if successor < len(keys) && keys[successor] == probe {
+			successor++
+		}
+		predecessor := ceiling - 1
+		floor := successor - 1
+
+		check("floor", probe, floor, tree.Floor)
+		check("ceiling", probe, ceiling, tree.Ceiling)
+		check("predecessor", probe, predecessor, tree.Predecessor)
+		check("successor", probe, successor, tree.Successor)
+	}
+}
+
+func TestTreeOrderStatistics(t *testing.T) {
+	keys := []string{"", "a", "api", "api.foe.fum", "api.foo", "api.foo.bar", "api.foo.baz", "abc.123.456", "b", "ba", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix:3"}
+	for i := 1; i < node256Max; i += 6 {
+		keys = append(keys, string([]byte{'x', byte(i)}))
+	}
+	for i := 1; i < node256Max; i += 2 {
+		keys = append(keys, string([]byte{'y', byte(i)}))
+	}
+
+	tr := New[string]()
+	for _, k := range keys {
+		tr.Insert(Key(k), k)
+	}
+	// updates do not change counts
+	for _, k := range keys {
+		tr.Insert(Key(k), k)
+	}
+
+	check := func(keys []string) {
+		sort.Strings(keys)
+		assertKeyCounts(t, tr.(*tree[string]).root)
+
+		for i, k := range keys {
+			assert.Equal(t, i, tr.Rank(Key(k)), k)
+			key, value, found := tr.Select(i)
+			assert.True(t, found)
+			assert.Equal(t, k, key.String())
+			assert.Equal(t, k, value)
+		}
+		_, _, found := tr.Select(len(keys))
+		assert.False(t, found)
+		_, _, found = tr.Select(-1)
+		assert.False(t, found)
+
+		probes := []string{"ab", "api.", "api.foo.bb", "az", "bb", "c", "this:key:has:a:long:", "this:key:has:a:long:d", "x", "x\x00", "x\xff", "y\x02", "zz", "\x00"}
+		for _, probe := range probes {
+			assert.Equal(t, sort.SearchStrings(keys, probe), tr.Rank(Key(probe)), "%q", probe)
+		}
+		for _, start := range probes {
+			for _, end := range probes {
+				n := sort.SearchStrings(keys, end) - sort.SearchStrings(keys, start)
+				if n < 0 {
+					n = 0
+				}
+				assert.Equal(t, n, tr.CountRange(Key(start), Key(end)), "%q %q", start, end)
+			}
+			assert.Equal(t, len(keys)-sort.SearchStrings(keys, start), tr.CountRange(Key(start), nil))
+		}
+	}
+	check(keys)
+
+	remain := make([]string, 0)
+	for i, k := range keys {
+		if i%3 == 0 {
+			tr.Delete(Key(k))
+		} else {
+			remain = append(remain, k)
+		}
+	}
+	check(remain)
+}
+
+// assertKeyCounts checks numKeys of every node equals the number of leaves under it
+func assertKeyCounts[V any](t *testing.T, n *artNode[V]) int {
+	if n == nil {
+		return 0
+	}
+	if n.isLeaf() {
+		return 1
+	}
+	count := assertKeyCounts(t, n.node().zeroChild)
+	n.forEachChild(func(_ byte, child *artNode[V]) bool {
+		count += assertKeyCounts(t, child)
+		return true
+	})
+	assert.Equal(t, count, n.node().numKeys)
+	return count
+}
+
+func TestTreeLongestPrefix(t *testing.T) {
+	tree := New[string]()
+	_, _, found := tree.LongestPrefix(Key("/api"))
+	assert.False(t, found)
+
+	routes := []string{"/", "/api", "/api/v1/", "/api/v1/users", "/api/v2/", "/static/", "/tenants/acme/long/namespace/", "/tenants/acme/long/namespace/team/"}
+	for _, r := range routes {
+		tree.Insert(Key(r), r)
+	}
+
+	for _, d := range []struct {
+		key      string
+		expected string
+	}{
+		{"", ""},
+		{"api", ""},
+		{"/", "/"},
+		{"/a", "/"},
+		{"/api", "/api"},
+		{"/api/", "/api"},
+		{"/api/v1", "/api"},
+		{"/api/v1/", "/api/v1/"},
+		{"/api/v1/users", "/api/v1/users"},
+		{"/api/v1/users/42", "/api/v1/users"},
+		{"/api/v1/user", "/api/v1/"},
+		{"/api/v3/", "/api"},
+		{"/static/css/main.css", "/static/"},
+		{"/tenants/acme/long/namespace/team/x", "/tenants/acme/long/namespace/team/"},
+		{"/tenants/acme/long/namespace/x", "/tenants/acme/long/namespace/"},
+		{"/tenants/acme/long/namespaces", "/"},
+		{"/tenants/acme/short/namespace/team/x", "/"},
+	} {
+		key, value, found := tree.LongestPrefix(Key(d.key))
+		if d.expected == "" {
+			assert.False(t, found, d.key)
+			continue
+		}
+		assert.True(t, found, d.key)
+		assert.Equal(t, d.expected, key.String(), d.key)
+		assert.Equal(t, d.expected, value, d.key)
+	}
+}
+
 func TestBigKeySetPrefixSearch(t *testing.T) {
 	keys := getKeys("1mvl5_10")
 
@@ -129,12 +734,12 @@ func TestBigKeySetPrefixSearch(t *testing.T) {
 	fmt.Printf("key len %d\n", n)
 
 	prefixs := make([]string, 0, n/10)
-	tree := New()
+	tree := New[string]()
 	for _, k := range keys {
 		if strings.HasPrefix(k, "z") {
 			prefixs = append(prefixs, k)
 		}
-		tree.Insert(Key(k))
+		tree.Insert(Key(k), k)
 	}
 	got := tree.ForEachKeyPrefix(Key("z"))
 	assert.Equal(t, prefixs, got)
@@ -173,10 +778,10 @@ func BenchmarkWordsTreeInsert(b *testing.B) {
 		b.ResetTimer()
 
 		for i := 0; i < b.N/n; i++ {
-			tree := New()
+			tree := New[string]()
 
 			for _, k := range keys {
-				tree.Insert(Key(k))
+				tree.Insert(Key(k), k)
 			}
 		}
 
@@ -194,10 +799,10 @@ func BenchmarkWordsTreePrefixSearch(b *testing.B) {
 		b.ResetTimer()
 
 		for i := 0; i < b.N/n; i++ {
-			tree := New()
+			tree := New[string]()
 
 			for _, k := range keys {
-				tree.Insert(Key(k))
+				tree.Insert(Key(k), k)
 			}
 
 			for _, prefix := range prefixs {
@@ -207,4 +812,324 @@ func BenchmarkWordsTreePrefixSearch(b *testing.B) {
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+func TestTreeWatch(t *testing.T) {
+	tree := New[int]()
+	events, cancel := tree.Watch(Key("api."))
+	all, cancelAll := tree.Watch(nil)
+
+	tree.Insert(Key("api.foo"), 1)
+	tree.Insert(Key("api.foo"), 2)
+	tree.Insert(Key("apix"), 3)
+	tree.Delete(Key("api.foo"))
+	tree.Delete(Key("api.missing"))
+
+	assert.Equal(t, Event[int]{Op: OpInsert, Key: Key("api.foo"), New: 1}, <-events)
+	assert.Equal(t, Event[int]{Op: OpUpdate, Key: Key("api.foo"), Old: 1, New: 2}, <-events)
+	assert.Equal(t, Event[int]{Op: OpDelete, Key: Key("api.foo"), Old: 2}, <-events)
+	assert.Len(t, events, 0)
+	assert.Len(t, all, 4)
+
+	cancel()
+	cancel()
+	_, open := <-events
+	assert.False(t, open)
+	tree.Insert(Key("api.bar"), 4)
+	cancelAll()
+}
+
+func TestTreeWatchOverflow(t *testing.T) {
+	tree := New[int]()
+	events, cancel := tree.Watch(nil)
+	defer cancel()
+
+	for i := 0; i < watchBufferSize+10; i++ {
+		tree.Insert(Key(fmt.Sprint(i)), i)
+	}
+	assert.Len(t, events, watchBufferSize+1)
+	for i := 0; i < watchBufferSize; i++ {
+		assert.Equal(t, OpInsert, (<-events).Op)
+	}
+	assert.Equal(t, Event[int]{Op: OpOverflow}, <-events)
+
+	// delivery resumes once the channel is drained
+	tree.Delete(Key("0"))
+	assert.Equal(t, Event[int]{Op: OpDelete, Key: Key("0")}, <-events)
+}
+
+type sliceIterator struct {
+	keys []string
+}
+
+func (it *sliceIterator) HasNext() bool {
+	return len(it.keys) > 0
+}
+
+func (it *sliceIterator) Next() (Node[int], error) {
+	key := it.keys[0]
+	it.keys = it.keys[1:]
+	return newLeaf(Key(key), len(key)), nil
+}
+
+func TestTreeBuildFromSorted(t *testing.T) {
+	src := New[int]()
+	for _, k := range []string{"", "a", "ab", "abc", "api.foo", "api.foo.bar", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2", "this:key:has:a:long:prefix"} {
+		src.Insert(Key(k), len(k))
+	}
+	for i := 0; i < 256; i++ {
+		src.Insert(Key([]byte{'z', byte(i)}), 2)
+	}
+
+	built, err := BuildFromSorted(src.Iterator(TraverseAll))
+	assert.NoError(t, err)
+	assert.Equal(t, src.Size(), built.Size())
+	assert.Equal(t, src.ForEachKeyPrefix(nil), built.ForEachKeyPrefix(nil))
+	assert.Equal(t, src.ForEachKeyPrefix(Key("this:key:has:a:long:c")), built.ForEachKeyPrefix(Key("this:key:has:a:long:c")))
+	for _, k := range src.ForEachKeyPrefix(nil) {
+		v, found := built.Search(Key(k))
+		assert.True(t, found, k)
+		assert.Equal(t, len(k), v)
+	}
+	assertKeyCounts(t, built.(*tree[int]).root)
+	assert.NoError(t, built.Validate())
+
+	// every inner node has its final type
+	for it := built.Iterator(TraverseNode); it.HasNext(); {
+		n, _ := it.Next()
+		an := n.(*artNode[int])
+		switch an.Type() {
+		case Node16:
+			assert.Greater(t, int(an.node().numChildren), node4Max)
+		case Node48:
+			assert.Greater(t, int(an.node().numChildren), node16Max)
+		case Node256:
+			assert.Greater(t, int(an.node().numChildren), node48Max)
+		}
+	}
+
+	// random keys with long shared prefixes and keys which are prefixes of others
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 50; round++ {
+		inserted := New[int]()
+		for k, v := range randomSetKeys(r, r.Intn(300)) {
+			inserted.Insert(Key(k), v)
+		}
+		result, err := BuildFromSorted(inserted.Iterator())
+		assert.NoError(t, err)
+		assert.Equal(t, inserted.ForEachKeyPrefix(nil), result.ForEachKeyPrefix(nil))
+		assert.NoError(t, result.Validate())
+		assertKeyCounts(t, result.(*tree[int]).root)
+		for it := inserted.Iterator(); it.HasNext(); {
+			n, _ := it.Next()
+			v, _ := result.Search(n.Key())
+			assert.Equal(t, n.Value(), v)
+		}
+	}
+
+	built.Insert(Key("this:key:has:a:long:common:prefix:3"), 0)
+	built.Delete(Key("ab"))
+	assert.Equal(t, []string{"a", "abc", "api.foo", "api.foo.bar"}, built.ForEachKeyPrefix(Key("a")))
+	assertKeyCounts(t, built.(*tree[int]).root)
+
+	empty, err := BuildFromSorted[int](&sliceIterator{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, empty.Size())
+
+	_, err = BuildFromSorted[int](&sliceIterator{keys: []string{"a", "b", "b"}})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	_, err = BuildFromSorted[int](&sliceIterator{keys: []string{"a", "c", "b"}})
+	assert.ErrorIs(t, err, ErrUnsortedKeys)
+}
+
+func TestTreeBatch(t *testing.T) {
+	tree := New[int]().(*tree[int])
+	expected := New[int]()
+	for _, k := range []string{"api.foo", "this:key:has:a:long:common:prefix:1", "z"} {
+		tree.Insert(Key(k), -1)
+		expected.Insert(Key(k), -1)
+	}
+	events, cancel := tree.Watch(Key("api."))
+	defer cancel()
+
+	var keys []Key
+	var values []int
+	for i := 0; i < 200; i++ {
+		keys = append(keys, Key(fmt.Sprintf("api.%d", i%150)), Key(fmt.Sprintf("this:key:has:a:long:common:prefix:%d", i%70)), Key(fmt.Sprintf("z%c", byte(i))))
+		values = append(values, i, i, i)
+	}
+	keys = append(keys, Key(""), Key("api.foo"), Key("api"), Key("this:key"), Key("api."))
+	values = append(values, 1, 2, 3, 4, 5)
+
+	old, updated := tree.InsertBatch(keys, values)
+	for i, k := range keys {
+		expectedOld, expectedUpdated := expected.Insert(k, values[i])
+		assert.Equal(t, expectedUpdated, updated[i], k)
+		assert.Equal(t, expectedOld, old[i], k)
+	}
+	assert.Equal(t, expected.Size(), tree.Size())
+	assert.Equal(t, expected.ForEachKeyPrefix(nil), tree.ForEachKeyPrefix(nil))
+	assertKeyCounts(t, tree.root)
+	assert.NoError(t, tree.Validate())
+	assert.Equal(t, Event[int]{Op: OpInsert, Key: Key("api.0"), New: 0}, <-events)
+
+	search := append(keys, Key("api.foo.bar"), Key("this:key:has:a:long:common:prefix:"), Key("this:key:has:a:short:prefix"), Key("q"))
+	values, found := tree.SearchBatch(search)
+	for i, k := range search {
+		expectedValue, expectedFound := expected.Search(k)
+		assert.Equal(t, expectedFound, found[i], k)
+		assert.Equal(t, expectedValue, values[i], k)
+	}
+
+	values, found = New[int]().SearchBatch(search)
+	assert.Len(t, values, len(search))
+	assert.NotContains(t, found, true)
+
+	// lengths are checked before the tree is modified
+	size := tree.Size()
+	events, cancel = tree.Watch(nil)
+	defer cancel()
+	assert.PanicsWithValue(t, ErrBatchLength, func() { tree.InsertBatch([]Key{Key("q1"), Key("q2")}, []int{1}) })
+	assert.PanicsWithValue(t, ErrBatchLength, func() { tree.InsertBatch([]Key{Key("q1")}, []int{1, 2}) })
+	assert.Equal(t, size, tree.Size())
+	assert.False(t, tree.Contains(Key("q1")))
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestTreeStats(t *testing.T) {
+	tree := New[int]()
+	assert.Equal(t, Stats{Nodes: map[NodeType]int{}, PrefixLens: map[uint32]int{}, FillRatios: map[NodeType]float64{}}, tree.Stats())
+
+	for _, k := range []string{"a", "ab", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2"} {
+		tree.Insert(Key(k), 0)
+	}
+	for i := 0; i < 20; i++ {
+		tree.Insert(Key(fmt.Sprintf("m%c", 'a'+i)), 0)
+	}
+
+	stats := tree.Stats()
+	assert.Equal(t, map[NodeType]int{Node4: 3, Node48: 1}, stats.Nodes)
+	assert.Equal(t, 24, stats.Leaves)
+	assert.Equal(t, 2, stats.MaxDepth)
+	assert.Equal(t, 2.0, stats.AvgDepth)
+	assert.Equal(t, map[uint32]int{0: 3, 33: 1}, stats.PrefixLens)
+	assert.Equal(t, 1, stats.LongPrefixes)
+	assert.Equal(t, 1, stats.ZeroChildren)
+	assert.Equal(t, map[NodeType]float64{Node4: 6.0 / 12, Node48: 20.0 / 48}, stats.FillRatios)
+}
+
+func TestTreeValidate(t *testing.T) {
+	tr := New[int]().(*tree[int])
+	assert.NoError(t, tr.Validate())
+
+	keys := []string{"", "a", "ab", "api.foo", "this:key:has:a:long:common:prefix:1", "this:key:has:a:long:common:prefix:2"}
+	for i := 0; i < 60; i++ {
+		keys = append(keys, fmt.Sprintf("m%c", 'A'+i), fmt.Sprintf("n%c", 'A'+i%10))
+	}
+	for _, k := range keys {
+		tr.Insert(Key(k), 0)
+	}
+	assert.NoError(t, tr.Validate())
+	for _, k := range keys[:40] {
+		tr.Delete(Key(k))
+		assert.NoError(t, tr.Validate(), k)
+	}
+
+	corrupt := func(key string, f func(n *artNode[int])) error {
+		ct := New[int]().(*tree[int])
+		for _, k := range keys {
+			ct.Insert(Key(k), 0)
+		}
+		n := ct.root
+		for _, c := range []byte(key) {
+			n = n.child(c, true)
+		}
+		f(n)
+		return ct.Validate()
+	}
+
+	err := corrupt("", func(n *artNode[int]) {
+		n.node().numKeys++
+	})
+	assert.Equal(t, &InvariantError{Path: "root", Reason: "numKeys is 77 but there are 76 leaves"}, err)
+
+	err = corrupt("t", func(n *artNode[int]) {
+		n.node().prefix[0] = 'x'
+	})
+	assert.Equal(t, "art: invariant broken at root/'t': prefix \"xis:key:ha\" of length 33 does not match minimum leaf \"this:key:has:a:long:common:prefix:1\"", fmt.Sprint(err))
+
+	err = corrupt("n", func(n *artNode[int]) {
+		n16 := n.node16()
+		n16.keys[0], n16.keys[1] = n16.keys[1], n16.keys[0]
+	})
+	assert.Contains(t, fmt.Sprint(err), "root/'n': keys \"BACDEFGHIJ\" are not sorted")
+
+	err = corrupt("m", func(n *artNode[int]) {
+		n256 := n.node256()
+		n256.children['A'], n256.children['B'] = n256.children['B'], n256.children['A']
+	})
+	assert.Contains(t, fmt.Sprint(err), "root/'m'/'A': key \"mB\" is under the wrong child")
+
+	err = corrupt("", func(n *artNode[int]) {
+		n.node().zeroChild = nil
+	})
+	assert.Contains(t, fmt.Sprint(err), "root: numKeys")
+
+	// an inner node without leaves is reported at its own path
+	empty := func(keys []string, path string) error {
+		ct := New[int]().(*tree[int])
+		for _, k := range keys {
+			ct.Insert(Key(k), 0)
+		}
+		n := ct.root
+		for _, c := range []byte(path) {
+			n = n.child(c, true)
+		}
+		*n.node4() = node4[int]{}
+		return ct.Validate()
+	}
+	assert.Equal(t, &InvariantError{Path: "root/'c'", Reason: "Node4 has 0 children"}, empty([]string{"a", "b", "cd1", "cd2"}, "c"))
+	assert.Equal(t, &InvariantError{Path: "root/'a'/'1'", Reason: "Node4 has 0 children"}, empty([]string{"a1x", "a1y", "a2", "b"}, "a1"))
+
+	tr.size++
+	assert.Contains(t, fmt.Sprint(tr.Validate()), "root: size is")
+}
+
+func TestTreeDump(t *testing.T) {
+	tree := New[int]()
+	var buf strings.Builder
+	assert.NoError(t, tree.Dump(&buf, DumpASCII))
+	assert.Equal(t, "<empty>\n", buf.String())
+
+	for _, k := range []string{"api", "api.foo", "api.bar", "this:key:has:a:long:prefix:1", "this:key:has:a:long:prefix:2"} {
+		tree.Insert(Key(k), 0)
+	}
+
+	buf.Reset()
+	assert.NoError(t, tree.Dump(&buf, DumpASCII))
+	assert.Equal(t, `Node4 prefix="" len=0 keys=5
+|-- 'a': Node4 prefix="pi" len=2 keys=3
+|   |-- zero: Leaf "api"
+|   `+"`"+`-- '.': Node4 prefix="" len=0 keys=2
+|       |-- 'b': Leaf "api.bar"
+|       `+"`"+`-- 'f': Leaf "api.foo"
+`+"`"+`-- 't': Node4 prefix="his:key:ha"... len=26 keys=2
+    |-- '1': Leaf "this:key:has:a:long:prefix:1"
+    `+"`"+`-- '2': Leaf "this:key:has:a:long:prefix:2"
+`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, tree.Dump(&buf, DumpDOT))
+	dot := buf.String()
+	assert.True(t, strings.HasPrefix(dot, "digraph art {\n"))
+	assert.Contains(t, dot, "\tn1 [label=\"Node4 prefix=\\\"pi\\\" len=2 keys=3\"];\n")
+	assert.Contains(t, dot, "\tn2 [label=\"Leaf \\\"api\\\"\" shape=ellipse];\n")
+	assert.Contains(t, dot, "\tn1 -> n2 [label=\"zero\"];\n")
+	assert.Contains(t, dot, "\tn0 -> n6 [label=\"'t'\"];\n")
+	assert.Equal(t, 8, strings.Count(dot, "->"))
+
+	assert.ErrorIs(t, tree.Dump(&buf, DumpFormat(9)), ErrDumpFormat)
+}
@@ -0,0 +1,85 @@
+package art
+
+// Txn starts a transaction based on t, t itself is never modified.
+func (t *ImmutableTree[V]) Txn() *Txn[V] {
+	return &Txn[V]{
+		base: t,
+		tree: t.tree.copyOnWrite(),
+	}
+}
+
+// Insert panics with ErrTxnClosed once the transaction is committed or aborted, so do the other
+// methods except Commit and Abort.
+func (txn *Txn[V]) Insert(key Key, value V) (V, bool) {
+	return txn.open().Insert(key, value)
+}
+
+func (txn *Txn[V]) Delete(key Key) (V, bool) {
+	tree := txn.open()
+	if !tree.Contains(key) {
+		var zero V
+		return zero, false
+	}
+	return tree.Delete(key)
+}
+
+// Search sees modifications staged by the transaction.
+func (txn *Txn[V]) Search(key Key) (V, bool) {
+	return txn.open().Search(key)
+}
+
+func (txn *Txn[V]) Contains(key Key) bool {
+	return txn.open().Contains(key)
+}
+
+func (txn *Txn[V]) Size() int {
+	return txn.open().Size()
+}
+
+// open returns the staged tree, it panics with ErrTxnClosed if the transaction is closed
+func (txn *Txn[V]) open() *tree[V] {
+	if txn.tree == nil {
+		panic(ErrTxnClosed)
+	}
+	return txn.tree
+}
+
+// Commit returns the tree with all staged modifications. A transaction of an AtomicTree
+// also publishes it as the current version, which fails with ErrTxnConflict if another
+// transaction has been committed since this one started.
+func (txn *Txn[V]) Commit() (*ImmutableTree[V], error) {
+	if txn.tree == nil {
+		return nil, ErrTxnClosed
+	}
+
+	committed := txn.tree.freeze()
+	txn.tree = nil
+
+	if txn.target != nil && !txn.target.CompareAndSwap(txn.base, committed) {
+		return nil, ErrTxnConflict
+	}
+	return committed, nil
+}
+
+// Abort discards all staged modifications, aborting a closed transaction does nothing.
+func (txn *Txn[V]) Abort() {
+	txn.tree = nil
+}
+
+func NewAtomic[V any]() *AtomicTree[V] {
+	t := &AtomicTree[V]{}
+	t.current.Store(NewImmutable[V]())
+	return t
+}
+
+// Load returns the current version, it stays unchanged by later transactions.
+func (t *AtomicTree[V]) Load() *ImmutableTree[V] {
+	return t.current.Load().(*ImmutableTree[V])
+}
+
+// Txn starts a transaction based on the current version.
+func (t *AtomicTree[V]) Txn() *Txn[V] {
+	txn := t.Load().Txn()
+	txn.target = &t.current
+	return txn
+}
@@ -0,0 +1,140 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxn(t *testing.T) {
+	base := NewImmutable[int]()
+	base, _, _ = base.Insert(Key("keep"), 0)
+	base, _, _ = base.Insert(Key("drop"), 0)
+
+	txn := base.Txn()
+	for i := 0; i < 100; i++ {
+		txn.Insert(Key(fmt.Sprintf("k%03d", i)), i)
+	}
+	// a node created by the transaction is modified in place
+	txn.Insert(Key("k000"), -1)
+	_, deleted := txn.Delete(Key("drop"))
+	assert.True(t, deleted)
+	_, deleted = txn.Delete(Key("missing"))
+	assert.False(t, deleted)
+
+	v, found := txn.Search(Key("k000"))
+	assert.True(t, found)
+	assert.Equal(t, -1, v)
+	assert.False(t, txn.Contains(Key("drop")))
+	assert.Equal(t, 101, txn.Size())
+
+	// nothing is visible before commit
+	assert.Equal(t, 2, base.Size())
+	assert.False(t, base.Contains(Key("k000")))
+
+	committed, err := txn.Commit()
+	assert.NoError(t, err)
+	assert.Equal(t, 101, committed.Size())
+	assert.True(t, committed.Contains(Key("keep")))
+	assert.False(t, committed.Contains(Key("drop")))
+	assertKeyCounts(t, committed.tree.root)
+
+	assert.Equal(t, 2, base.Size())
+	assert.True(t, base.Contains(Key("drop")))
+
+	_, err = txn.Commit()
+	assert.Equal(t, ErrTxnClosed, err)
+
+	aborted := committed.Txn()
+	aborted.Insert(Key("k000"), 1000)
+	aborted.Delete(Key("keep"))
+	aborted.Abort()
+	_, err = aborted.Commit()
+	assert.Equal(t, ErrTxnClosed, err)
+
+	v, _ = committed.Search(Key("k000"))
+	assert.Equal(t, -1, v)
+	assert.True(t, committed.Contains(Key("keep")))
+}
+
+func TestTxnClosed(t *testing.T) {
+	committed := NewImmutable[int]().Txn()
+	_, err := committed.Commit()
+	assert.NoError(t, err)
+	aborted := NewImmutable[int]().Txn()
+	aborted.Abort()
+	aborted.Abort()
+
+	for _, txn := range []*Txn[int]{committed, aborted} {
+		assert.PanicsWithValue(t, ErrTxnClosed, func() { txn.Insert(Key("k"), 0) })
+		assert.PanicsWithValue(t, ErrTxnClosed, func() { txn.Delete(Key("k")) })
+		assert.PanicsWithValue(t, ErrTxnClosed, func() { txn.Search(Key("k")) })
+		assert.PanicsWithValue(t, ErrTxnClosed, func() { txn.Contains(Key("k")) })
+		assert.PanicsWithValue(t, ErrTxnClosed, func() { txn.Size() })
+		_, err := txn.Commit()
+		assert.Equal(t, ErrTxnClosed, err)
+	}
+}
+
+func TestAtomicTreeTxn(t *testing.T) {
+	tree := NewAtomic[int]()
+
+	first, second := tree.Txn(), tree.Txn()
+	first.Insert(Key("a"), 1)
+	second.Insert(Key("b"), 2)
+
+	committed, err := first.Commit()
+	assert.NoError(t, err)
+	assert.Equal(t, committed, tree.Load())
+
+	_, err = second.Commit()
+	assert.Equal(t, ErrTxnConflict, err)
+	assert.False(t, tree.Load().Contains(Key("b")))
+}
+
+func TestAtomicTreeConcurrentReaders(t *testing.T) {
+	const batches, batchSize = 50, 20
+	tree := NewAtomic[int]()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// a reader sees whole batches only
+				version := tree.Load()
+				assert.Equal(t, 0, version.Size()%batchSize)
+				for b := 0; b < version.Size()/batchSize; b++ {
+					for i := 0; i < batchSize; i++ {
+						v, found := version.Search(Key(fmt.Sprintf("%d:%d", b, i)))
+						assert.True(t, found)
+						assert.Equal(t, b, v)
+					}
+				}
+			}
+		}()
+	}
+
+	for b := 0; b < batches; b++ {
+		txn := tree.Txn()
+		for i := 0; i < batchSize; i++ {
+			txn.Insert(Key(fmt.Sprintf("%d:%d", b, i)), b)
+		}
+		_, err := txn.Commit()
+		assert.NoError(t, err)
+	}
+	close(stop)
+	wg.Wait()
+
+	assert.Equal(t, batches*batchSize, tree.Load().Size())
+}
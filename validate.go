@@ -0,0 +1,189 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("art: invariant broken at %s: %s", e.Path, e.Reason)
+}
+
+func (t *tree[V]) Validate() error {
+	v := &validator[V]{path: []string{"root"}}
+	leaves, _, err := v.validate(t.root, 0)
+	if err != nil {
+		return err
+	}
+	if leaves != t.Size() {
+		return v.fail("size is %d but there are %d leaves", t.Size(), leaves)
+	}
+	return nil
+}
+
+type validator[V any] struct {
+	// steps from the root to the current node
+	path []string
+}
+
+func (v *validator[V]) fail(format string, args ...interface{}) error {
+	return &InvariantError{Path: strings.Join(v.path, "/"), Reason: fmt.Sprintf(format, args...)}
+}
+
+// validate checks the subtree of n whose prefix starts at depth, it returns the number of leaves
+// and the minimum leaf, children are checked before the prefix is compared with their minimum
+func (v *validator[V]) validate(n *artNode[V], depth uint32) (int, *leaf[V], error) {
+	if n == nil {
+		return 0, nil, nil
+	}
+	if n.isLeaf() {
+		return 1, n.leaf(), nil
+	}
+
+	if err := v.validateChildren(n); err != nil {
+		return 0, nil, err
+	}
+
+	node := n.node()
+	end := depth + node.prefixLen
+
+	leaves := 0
+	var minimum *leaf[V]
+	if node.zeroChild != nil {
+		v.path = append(v.path, "zero")
+		if !node.zeroChild.isLeaf() {
+			return 0, nil, v.fail("zeroChild is a %s", node.zeroChild._type)
+		}
+		minimum = node.zeroChild.leaf()
+		if uint32(len(minimum.key)) != end {
+			return 0, nil, v.fail("key %q does not end at %d", minimum.key, end)
+		}
+		leaves++
+		v.path = v.path[:len(v.path)-1]
+	}
+
+	var err error
+	n.forEachChild(func(c byte, child *artNode[V]) bool {
+		v.path = append(v.path, fmt.Sprintf("%q", c))
+		count, first, childErr := v.validate(child, end+1)
+		switch {
+		case childErr != nil:
+			err = childErr
+			return false
+		case first == nil:
+			err = v.fail("no leaf")
+			return false
+		case !first.key.valid(int(end)) || first.key[end] != c:
+			err = v.fail("key %q is under the wrong child", first.key)
+			return false
+		}
+		if minimum == nil {
+			minimum = first
+		}
+		leaves += count
+		v.path = v.path[:len(v.path)-1]
+		return true
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if minimum == nil {
+		return 0, nil, v.fail("no leaf")
+	}
+	stored := node.prefix[:min(node.prefixLen, MaxPrefixLen)]
+	if uint32(len(minimum.key)) < end || !bytes.Equal(minimum.key[depth:depth+uint32(len(stored))], stored) {
+		return 0, nil, v.fail("prefix %q of length %d does not match minimum leaf %q", stored, node.prefixLen, minimum.key)
+	}
+	if node.numKeys != leaves {
+		return 0, nil, v.fail("numKeys is %d but there are %d leaves", node.numKeys, leaves)
+	}
+	return leaves, minimum, nil
+}
+
+// validateChildren checks the layout of children of n
+func (v *validator[V]) validateChildren(n *artNode[V]) error {
+	node := n.node()
+	numChildren := int(node.numChildren)
+
+	// a node4 with a single child besides zeroChild is collapsed
+	size := numChildren
+	if node.zeroChild != nil && n._type == Node4 {
+		size++
+	}
+	bounds := map[NodeType][2]int{
+		Node4:   {node4Min, node4Max},
+		Node16:  {node16Min, node16Max},
+		Node48:  {node48Min, node48Max},
+		Node256: {node256Min, node256Max},
+	}[n._type]
+	if size < bounds[0] || numChildren > bounds[1] {
+		return v.fail("%s has %d children", n._type, numChildren)
+	}
+
+	switch n._type {
+	case Node4, Node16:
+		var keys []byte
+		var children []*artNode[V]
+		var present func(i int) bool
+		if n._type == Node4 {
+			n4 := n.node4()
+			keys, children = n4.keys[:], n4.children[:]
+			present = func(i int) bool { return n4.present[i] != 0 }
+		} else {
+			n16 := n.node16()
+			keys, children = n16.keys[:], n16.children[:]
+			present = func(i int) bool { return n16.present&(1<<i) != 0 }
+		}
+		for i := range children {
+			used := i < numChildren
+			if present(i) != used || (children[i] != nil) != used {
+				return v.fail("slot %d of %d children is present %v with child %v", i, numChildren, present(i), children[i] != nil)
+			}
+			if used && i > 0 && keys[i-1] >= keys[i] {
+				return v.fail("keys %q are not sorted", keys[:numChildren])
+			}
+		}
+
+	case Node48:
+		n48 := n.node48()
+		present := 0
+		for _, bitmap := range n48.present {
+			present += bits.OnesCount64(bitmap)
+		}
+		if present != numChildren {
+			return v.fail("%d keys are present for %d children", present, numChildren)
+		}
+
+		used := map[byte]bool{}
+		for c := 0; c < node256Max; c++ {
+			if n48.present[c>>n48s]&(1<<(c%n48m)) == 0 {
+				continue
+			}
+			idx := n48.keys[c]
+			if int(idx) >= node48Max || n48.children[idx] == nil || used[idx] {
+				return v.fail("key %q points at slot %d which is invalid, empty or shared", byte(c), idx)
+			}
+			used[idx] = true
+		}
+		for idx, child := range n48.children {
+			if child != nil && !used[byte(idx)] {
+				return v.fail("slot %d is not pointed at by any key", idx)
+			}
+		}
+
+	case Node256:
+		count := 0
+		for _, child := range n.node256().children {
+			if child != nil {
+				count++
+			}
+		}
+		if count != numChildren {
+			return v.fail("%d children for numChildren %d", count, numChildren)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package art
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+func (t *tree[V]) Watch(prefix Key) (<-chan Event[V], func()) {
+	w := &watcher[V]{
+		prefix: append(Key(nil), prefix...),
+		events: make(chan Event[V], watchBufferSize+1),
+	}
+
+	ws := &t.watchers
+	ws.Lock()
+	if ws.list == nil {
+		ws.list = make(map[*watcher[V]]struct{})
+	}
+	ws.list[w] = struct{}{}
+	atomic.AddInt32(&ws.count, 1)
+	ws.Unlock()
+
+	cancel := func() {
+		ws.Lock()
+		defer ws.Unlock()
+		if _, ok := ws.list[w]; ok {
+			delete(ws.list, w)
+			atomic.AddInt32(&ws.count, -1)
+			close(w.events)
+		}
+	}
+	return w.events, cancel
+}
+
+func (o Op) String() string {
+	return []string{"Insert", "Update", "Delete", "Overflow"}[o]
+}
+
+// notify sends an event to the watchers of key
+func (ws *watchers[V]) notify(op Op, key Key, old, new V) {
+	if atomic.LoadInt32(&ws.count) == 0 {
+		return
+	}
+
+	ws.Lock()
+	defer ws.Unlock()
+
+	var event *Event[V]
+	for w := range ws.list {
+		if !bytes.HasPrefix(key, w.prefix) {
+			continue
+		}
+		if event == nil {
+			event = &Event[V]{Op: op, Key: append(Key(nil), key...), Old: old, New: new}
+		}
+		w.send(*event)
+	}
+}
+
+// send never blocks, sends are serialized by the lock of watchers.
+// Only watchBufferSize events are buffered, the last slot is left for OpOverflow.
+func (w *watcher[V]) send(event Event[V]) {
+	if w.overflowed {
+		if len(w.events) > 0 {
+			return
+		}
+		w.overflowed = false
+	}
+
+	if len(w.events) < watchBufferSize {
+		w.events <- event
+		return
+	}
+	w.overflowed = true
+	w.events <- Event[V]{Op: OpOverflow}
+}